@@ -0,0 +1,181 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Configures how RunBatch executes its commands.
+//
+// Fields:
+//   - Concurrency: int - the maximum number of commands running at the same time,
+//     the number of CPUs if less than 1
+//   - FailFast: bool - stop at the first failure, killing running commands and skipping
+//     the ones not started yet; otherwise every command runs and all failures are collected
+type BatchOptions struct {
+	Concurrency int  `json:"concurrency" bson:"concurrency" yaml:"concurrency"`
+	FailFast    bool `json:"fail_fast" bson:"fail_fast" yaml:"fail_fast"`
+}
+
+// Holds the outcome of a single command of a batch.
+//
+// Fields:
+//   - Index: int - the position of the command in the batch
+//   - Command: string - the string representation of the command
+//   - Result: *CommandResult - the result, nil if the command was skipped or could not be started
+//   - Err: error - the error of the command, nil if it succeeded or was skipped; not serialized
+//   - Skipped: bool - true if the command was never started because the batch failed fast
+type BatchItem struct {
+	Index   int            `json:"index" bson:"index" yaml:"index"`
+	Command string         `json:"command" bson:"command" yaml:"command"`
+	Result  *CommandResult `json:"result" bson:"result" yaml:"result"`
+	Err     error          `json:"-" bson:"-" yaml:"-"`
+	Skipped bool           `json:"skipped" bson:"skipped" yaml:"skipped"`
+}
+
+// Holds aggregate statistics of a batch.
+//
+// Fields:
+//   - Total: int - the number of commands in the batch
+//   - Succeeded: int - the number of commands that exited successfully
+//   - Failed: int - the number of commands that failed or could not be started
+//   - Skipped: int - the number of commands that were never started
+//   - Duration: time.Duration - the wall-clock time of the whole batch
+//   - CommandTime: time.Duration - the sum of the durations of all commands
+//   - Slowest: time.Duration - the duration of the slowest command
+type BatchStats struct {
+	Total       int           `json:"total" bson:"total" yaml:"total"`
+	Succeeded   int           `json:"succeeded" bson:"succeeded" yaml:"succeeded"`
+	Failed      int           `json:"failed" bson:"failed" yaml:"failed"`
+	Skipped     int           `json:"skipped" bson:"skipped" yaml:"skipped"`
+	Duration    time.Duration `json:"duration" bson:"duration" yaml:"duration"`
+	CommandTime time.Duration `json:"command_time" bson:"command_time" yaml:"command_time"`
+	Slowest     time.Duration `json:"slowest" bson:"slowest" yaml:"slowest"`
+}
+
+// Holds the outcome of RunBatch.
+//
+// Fields:
+//   - Items: []BatchItem - the outcome of every command, in the order of the batch
+//   - Stats: BatchStats - aggregate statistics
+type BatchResult struct {
+	Items []BatchItem `json:"items" bson:"items" yaml:"items"`
+	Stats BatchStats  `json:"stats" bson:"stats" yaml:"stats"`
+}
+
+// Executes a batch of commands using a bounded pool of workers. Results are returned
+// in the order of the commands, regardless of the order in which they finished.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of the whole batch
+//   - cmds: []*Command - the commands to execute
+//   - options: BatchOptions - the concurrency limit and error mode
+//
+// Returns:
+//   - *BatchResult: the outcome of every command and aggregate statistics
+//   - error: the first failure in fail-fast mode, all failures joined otherwise, nil if all succeeded
+//
+// Example usage:
+//
+//	var cmds []*Command
+//	for _, repo := range repos {
+//	  cmds = append(cmds, NewCommand("git", "pull").WithDir(filepath.Join("repositories", repo)))
+//	}
+//	batch, err := RunBatch(ctx, cmds, BatchOptions{Concurrency: 8})
+//	fmt.Printf("%d of %d succeeded\n", batch.Stats.Succeeded, batch.Stats.Total)
+func RunBatch(ctx context.Context, cmds []*Command, options BatchOptions) (*BatchResult, error) {
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = runtime.NumCPU()
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	batch := &BatchResult{Items: make([]BatchItem, len(cmds))}
+	start := time.Now()
+
+	var mu sync.Mutex
+	var firstErr error
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(cmds); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				item := &batch.Items[i]
+				item.Index = i
+				item.Command = cmds[i].String()
+
+				if ctx.Err() != nil {
+					item.Skipped = true
+					continue
+				}
+
+				item.Result, item.Err = cmds[i].Run(ctx)
+				if item.Err != nil && options.FailFast {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = item.Err
+						cancel()
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range cmds {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	batch.Stats = batchStats(batch.Items)
+	batch.Stats.Duration = time.Since(start)
+
+	if firstErr != nil {
+		return batch, firstErr
+	}
+
+	var errs []error
+	for _, item := range batch.Items {
+		if item.Err != nil {
+			errs = append(errs, fmt.Errorf("command %d: %w", item.Index, item.Err))
+		}
+	}
+	if batch.Stats.Skipped > 0 {
+		errs = append(errs, fmt.Errorf("batch aborted, %d commands skipped: %w", batch.Stats.Skipped, parent.Err()))
+	}
+	return batch, errors.Join(errs...)
+}
+
+// Computes the statistics of the batch items, except the wall-clock duration.
+func batchStats(items []BatchItem) BatchStats {
+	stats := BatchStats{Total: len(items)}
+	for _, item := range items {
+		switch {
+		case item.Skipped:
+			stats.Skipped++
+		case item.Err != nil:
+			stats.Failed++
+		default:
+			stats.Succeeded++
+		}
+
+		if item.Result != nil {
+			stats.CommandTime += item.Result.Duration
+			if item.Result.Duration > stats.Slowest {
+				stats.Slowest = item.Result.Duration
+			}
+		}
+	}
+	return stats
+}
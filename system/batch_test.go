@@ -0,0 +1,116 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBatchOrderedResults(t *testing.T) {
+	var cmds []*Command
+	for i := 0; i < 6; i++ {
+		// Later commands finish first
+		cmds = append(cmds, NewShellCommand(fmt.Sprintf("sleep 0.%d; echo %d", 6-i, i)))
+	}
+
+	batch, err := RunBatch(context.Background(), cmds, BatchOptions{Concurrency: 6})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i, item := range batch.Items {
+		if item.Index != i || item.Result.StdoutString() != fmt.Sprintf("%d\n", i) {
+			t.Errorf("Item %d out of order: %+v", i, item)
+		}
+	}
+	if batch.Stats.Total != 6 || batch.Stats.Succeeded != 6 {
+		t.Errorf("Unexpected stats: %+v", batch.Stats)
+	}
+	if batch.Stats.CommandTime <= batch.Stats.Duration {
+		t.Errorf("Commands did not run concurrently: %+v", batch.Stats)
+	}
+}
+
+func TestRunBatchConcurrencyLimit(t *testing.T) {
+	var running, peak int32
+	var mu sync.Mutex
+
+	fake := NewFakeExecutor()
+	fake.On(`.`).Respond(func(cmd *Command) (*CommandResult, error) {
+		n := atomic.AddInt32(&running, 1)
+		mu.Lock()
+		if n > peak {
+			peak = n
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return &CommandResult{Command: cmd.String()}, nil
+	})
+
+	var cmds []*Command
+	for i := 0; i < 20; i++ {
+		cmds = append(cmds, NewCommand("job").WithExecutor(fake))
+	}
+
+	_, err := RunBatch(context.Background(), cmds, BatchOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if peak > 3 {
+		t.Errorf("Concurrency limit exceeded, peak was %d", peak)
+	}
+}
+
+func TestRunBatchCollectAll(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On(`^fail`).ExitCode(1)
+	fake.On(`^ok`)
+
+	cmds := []*Command{
+		NewCommand("fail", "1").WithExecutor(fake),
+		NewCommand("ok").WithExecutor(fake),
+		NewCommand("fail", "2").WithExecutor(fake),
+	}
+
+	batch, err := RunBatch(context.Background(), cmds, BatchOptions{Concurrency: 1})
+	if err == nil {
+		t.Fatal("Expected the failures to be reported")
+	}
+	if batch.Stats.Failed != 2 || batch.Stats.Succeeded != 1 || batch.Stats.Skipped != 0 {
+		t.Errorf("Unexpected stats: %+v", batch.Stats)
+	}
+	for _, item := range []BatchItem{batch.Items[0], batch.Items[2]} {
+		if !errors.Is(err, item.Err) {
+			t.Errorf("Joined error does not contain the failure of command %d", item.Index)
+		}
+	}
+}
+
+func TestRunBatchFailFast(t *testing.T) {
+	cmds := []*Command{
+		NewShellCommand("exit 2"),
+		NewShellCommand("sleep 30"),
+		NewShellCommand("echo never"),
+		NewShellCommand("echo never"),
+	}
+
+	start := time.Now()
+	batch, err := RunBatch(context.Background(), cmds, BatchOptions{Concurrency: 2, FailFast: true})
+	if err == nil || batch.Items[0].Err != err {
+		t.Fatalf("Expected the first failure to be returned, got: %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Running commands were not killed")
+	}
+	if batch.Stats.Skipped != 2 || batch.Stats.Failed != 2 {
+		t.Errorf("Unexpected stats: %+v", batch.Stats)
+	}
+	if !batch.Items[3].Skipped || batch.Items[3].Result != nil {
+		t.Errorf("Expected the last command to be skipped: %+v", batch.Items[3])
+	}
+}
@@ -0,0 +1,271 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Describes a program invocation with an explicit argument list.
+//
+// Unlike RunCommand, a Command never passes its arguments through a shell unless it was
+// created with NewShellCommand, so user-supplied values can be used as arguments without
+// any escaping. The With* methods configure the command and return it for chaining.
+//
+// Example usage:
+//
+//	result, err := NewCommand("git", "clone", url, target).
+//	  WithDir("repositories/").
+//	  WithEnv("GIT_TERMINAL_PROMPT", "0").
+//	  Run(ctx)
+type Command struct {
+	name       string
+	args       []string
+	shell      bool
+	dir        string
+	env        []string
+	unsetEnv   []string
+	inheritEnv bool
+	keepEnv    []string
+	stdin      io.Reader
+
+	lineHandler   LineHandler
+	maxLineLength int
+	outputLimit   *OutputLimit
+
+	resourceLimits *ResourceLimits
+	credentials    *Credentials
+
+	executor Executor
+}
+
+// Creates a Command executing the named program with the given arguments.
+// If name contains no path separators, it is resolved using the PATH environment variable.
+// The command inherits the environment of the current process by default.
+//
+// Parameters:
+//   - name: string - the program to execute
+//   - args: ...string - the arguments passed to the program, used verbatim
+//
+// Returns:
+//   - *Command: the configured command
+func NewCommand(name string, args ...string) *Command {
+	return &Command{
+		name:       name,
+		args:       append([]string(nil), args...),
+		inheritEnv: true,
+
+		maxLineLength: DefaultMaxLineLength,
+	}
+}
+
+// Creates a Command that runs the command line through the platform shell,
+// 'cmd /C' on Windows and '/bin/sh -c' on Unix-based systems.
+// Only use this for trusted command lines, the shell interprets every special character.
+//
+// Parameters:
+//   - cmdLine: string - the command line to be executed by the shell
+//
+// Returns:
+//   - *Command: the configured command
+func NewShellCommand(cmdLine string) *Command {
+	cmd := NewCommand(cmdLine)
+	cmd.shell = true
+	return cmd
+}
+
+// Returns the program name, or the command line for shell commands.
+func (c *Command) Name() string {
+	return c.name
+}
+
+// Returns a copy of the arguments passed to the program.
+func (c *Command) Args() []string {
+	return append([]string(nil), c.args...)
+}
+
+// Appends further arguments to the command.
+func (c *Command) WithArgs(args ...string) *Command {
+	c.args = append(c.args, args...)
+	return c
+}
+
+// Sets the working directory of the command. An empty directory means the current one.
+func (c *Command) WithDir(dir string) *Command {
+	c.dir = dir
+	return c
+}
+
+// Sets an environment variable for the command, overriding any inherited value.
+func (c *Command) WithEnv(key, value string) *Command {
+	c.env = append(c.env, key+"="+value)
+	return c
+}
+
+// Removes the given variables from the environment inherited by the command.
+// Variables set with WithEnv are not affected.
+func (c *Command) WithoutEnv(keys ...string) *Command {
+	c.unsetEnv = append(c.unsetEnv, keys...)
+	return c
+}
+
+// Stops the command from inheriting the environment of the current process.
+// Only the listed variables are passed through, plus everything set with WithEnv.
+func (c *Command) WithCleanEnv(keep ...string) *Command {
+	c.inheritEnv = false
+	c.keepEnv = append(c.keepEnv, keep...)
+	return c
+}
+
+// Connects the reader to the standard input of the command.
+// The reader is consumed by the first run of the command.
+func (c *Command) WithStdin(r io.Reader) *Command {
+	c.stdin = r
+	return c
+}
+
+// Feeds the string to the standard input of the command.
+func (c *Command) WithStdinString(input string) *Command {
+	return c.WithStdin(strings.NewReader(input))
+}
+
+// Returns the directory the command runs in, empty for the current directory.
+func (c *Command) Dir() string {
+	return c.dir
+}
+
+// Runs the command with the given executor instead of the default one.
+func (c *Command) WithExecutor(executor Executor) *Command {
+	c.executor = executor
+	return c
+}
+
+// Returns a human readable representation of the command, quoting arguments
+// that contain whitespace or shell metacharacters.
+func (c *Command) String() string {
+	if c.shell {
+		return c.name
+	}
+
+	parts := make([]string, 0, len(c.args)+1)
+	parts = append(parts, quoteArg(c.name))
+	for _, arg := range c.args {
+		parts = append(parts, quoteArg(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Runs the command and waits for it to finish, killing its whole process group once
+// the context is cancelled or its deadline expires.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of the command
+//
+// Returns:
+//   - *CommandResult: the outcome of the execution, nil if the command could not be started
+//   - error: an error if the command could not be started, did not exit successfully or
+//     timed out (wrapping ErrCommandTimeout)
+//
+// Example usage:
+//
+//	result, err := NewCommand("ls", "-l", path).Run(ctx)
+func (c *Command) Run(ctx context.Context) (*CommandResult, error) {
+	result, err := c.execute(ctx)
+	if err != nil && result != nil {
+		return result, fmt.Errorf("command failed: %s: %w", c, err)
+	}
+	return result, err
+}
+
+// Runs the command with its executor, returning the executor's unwrapped error.
+func (c *Command) execute(ctx context.Context) (*CommandResult, error) {
+	executor := c.executor
+	if executor == nil {
+		executor = DefaultExecutor()
+	}
+	return executor.Execute(ctx, c)
+}
+
+// Returns a copy of the command that can be modified without affecting the original.
+// The standard input, the line handler and the executor are shared.
+func (c *Command) clone() *Command {
+	clone := *c
+	clone.args = append([]string(nil), c.args...)
+	clone.env = append([]string(nil), c.env...)
+	clone.unsetEnv = append([]string(nil), c.unsetEnv...)
+	clone.keepEnv = append([]string(nil), c.keepEnv...)
+	return &clone
+}
+
+// Creates the exec.Cmd described by the command.
+func (c *Command) build(ctx context.Context) *exec.Cmd {
+	var cmd *exec.Cmd
+	if c.shell {
+		cmd = shellCommand(ctx, c.name)
+	} else {
+		cmd = exec.CommandContext(ctx, c.name, c.args...)
+	}
+
+	cmd.Dir = c.dir
+	cmd.Env = c.environ()
+	cmd.Stdin = c.stdin
+	return cmd
+}
+
+// Computes the environment of the command. Returns nil, meaning the environment of the
+// current process, if the command does not modify it.
+func (c *Command) environ() []string {
+	if c.inheritEnv && len(c.env) == 0 && len(c.unsetEnv) == 0 {
+		return nil
+	}
+
+	// A clean environment has to be non-nil, exec.Cmd inherits the current one otherwise
+	env := []string{}
+	if c.inheritEnv {
+		env = os.Environ()
+	} else {
+		for _, key := range c.keepEnv {
+			if value, ok := os.LookupEnv(key); ok {
+				env = append(env, key+"="+value)
+			}
+		}
+	}
+
+	for _, key := range c.unsetEnv {
+		env = removeEnv(env, key)
+	}
+	for _, entry := range c.env {
+		key, _, _ := strings.Cut(entry, "=")
+		env = append(removeEnv(env, key), entry)
+	}
+
+	return env
+}
+
+// Removes every entry for the given key from a KEY=VALUE environment list.
+func removeEnv(env []string, key string) []string {
+	filtered := env[:0]
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if name == key || (runtime.GOOS == "windows" && strings.EqualFold(name, key)) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+// Quotes an argument for display if it contains characters a shell would interpret.
+func quoteArg(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(arg, " \t\n'\"\\$`|&;<>()*?[]{}~#!") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
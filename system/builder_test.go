@@ -0,0 +1,124 @@
+package system
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandArgumentsAreNotInterpreted(t *testing.T) {
+	arg := "$HOME; echo injected | cat"
+	result, err := NewCommand("printf", "%s", arg).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+
+	if result.StdoutString() != arg {
+		t.Errorf("Argument was modified. Expected: %q, Got: %q", arg, result.Stdout)
+	}
+}
+
+func TestCommandDirAndStdin(t *testing.T) {
+	dir := t.TempDir()
+
+	result, err := NewCommand("pwd").WithDir(dir).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	expected, _ := filepath.EvalSymlinks(dir)
+	if strings.TrimSpace(result.StdoutString()) != expected {
+		t.Errorf("Unexpected working directory. Expected: %s, Got: %s", expected, result.Stdout)
+	}
+
+	result, err = NewCommand("cat").WithStdinString("from stdin").Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	if result.StdoutString() != "from stdin" {
+		t.Errorf("Unexpected output. Expected: %q, Got: %q", "from stdin", result.Stdout)
+	}
+}
+
+func TestCommandEnvironment(t *testing.T) {
+	t.Setenv("SYSTEM_TEST_INHERITED", "inherited")
+	t.Setenv("SYSTEM_TEST_REMOVED", "removed")
+
+	env := NewCommand("env").
+		WithEnv("SYSTEM_TEST_SET", "set").
+		WithoutEnv("SYSTEM_TEST_REMOVED").
+		environ()
+	assertEnv(t, env, "SYSTEM_TEST_INHERITED", "inherited")
+	assertEnv(t, env, "SYSTEM_TEST_SET", "set")
+	assertEnv(t, env, "SYSTEM_TEST_REMOVED", "")
+
+	env = NewCommand("env").
+		WithCleanEnv("SYSTEM_TEST_INHERITED").
+		WithEnv("SYSTEM_TEST_INHERITED", "overridden").
+		environ()
+	if len(env) != 1 {
+		t.Errorf("Expected a single variable in a clean environment, got %v", env)
+	}
+	assertEnv(t, env, "SYSTEM_TEST_INHERITED", "overridden")
+
+	if NewCommand("env").environ() != nil {
+		t.Error("Unmodified environment should be inherited as nil")
+	}
+
+	result, err := NewCommand("sh", "-c", "echo $SYSTEM_TEST_SET").
+		WithCleanEnv().
+		WithEnv("SYSTEM_TEST_SET", "value").
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	if result.StdoutString() != "value\n" {
+		t.Errorf("Unexpected output. Expected: %q, Got: %q", "value\n", result.Stdout)
+	}
+
+	// Without any variables the child must not fall back to the environment of this process
+	result, err = NewCommand("env").WithCleanEnv().Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	if result.StdoutString() != "" {
+		t.Errorf("Expected an empty environment, got: %q", result.Stdout)
+	}
+}
+
+func TestCommandString(t *testing.T) {
+	cmd := NewCommand("grep", "-r", "hello world", "it's")
+	expected := `grep -r 'hello world' 'it'\''s'`
+	if cmd.String() != expected {
+		t.Errorf("Unexpected string. Expected: %s, Got: %s", expected, cmd.String())
+	}
+
+	shell := NewShellCommand("ls | wc -l")
+	if shell.String() != "ls | wc -l" {
+		t.Errorf("Shell command should be shown verbatim, got: %s", shell.String())
+	}
+}
+
+func TestCommandNotFound(t *testing.T) {
+	result, err := NewCommand("system-test-missing-binary").Run(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error for a missing binary")
+	}
+	if result != nil {
+		t.Errorf("Expected no result for a command that could not start, got %+v", result)
+	}
+}
+
+func assertEnv(t *testing.T, env []string, key, expected string) {
+	t.Helper()
+
+	value := ""
+	for _, entry := range env {
+		if name, v, _ := strings.Cut(entry, "="); name == key {
+			value = v
+		}
+	}
+	if value != expected {
+		t.Errorf("Unexpected value for %s. Expected: %q, Got: %q", key, expected, value)
+	}
+}
@@ -0,0 +1,283 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Returned (wrapped) when a command was killed because its context deadline expired.
+// Use errors.Is(err, ErrCommandTimeout) to tell a timeout apart from other failures.
+var ErrCommandTimeout = errors.New("command timed out")
+
+// How long Wait keeps waiting for the output pipes to be closed once the process has
+// exited or was killed. Guards against background grandchildren keeping the pipes open.
+const commandWaitDelay = 5 * time.Second
+
+// Holds the outcome of a command execution, including the captured output,
+// the exit status and timing information.
+//
+// Fields:
+//   - Command: string - the command line that was executed
+//   - Stdout: []byte - everything the command wrote to its standard output
+//   - Stderr: []byte - everything the command wrote to its standard error
+//   - StdoutSize: int64 - the total number of bytes written to stdout, including discarded ones
+//   - StderrSize: int64 - the total number of bytes written to stderr, including discarded ones
+//   - StdoutTruncated: bool - true if parts of stdout were discarded because of an OutputLimit
+//   - StderrTruncated: bool - true if parts of stderr were discarded because of an OutputLimit
+//   - StdoutFile: string - the file holding the complete stdout, if spilling was requested
+//   - StderrFile: string - the file holding the complete stderr, if spilling was requested
+//   - PeakMemory: int64 - the peak memory usage in bytes, of the whole cgroup if one was used
+//   - UserTime: time.Duration - the CPU time spent in user mode
+//   - SystemTime: time.Duration - the CPU time spent in kernel mode
+//   - Cgroup: string - the transient cgroup the command ran in, empty if none
+//   - ExitCode: int - the exit code of the process, -1 if it was terminated by a signal
+//   - Signal: string - the signal that terminated the process, empty if it exited on its own
+//   - StartTime: time.Time - the moment the process was started
+//   - EndTime: time.Time - the moment the process finished
+//   - Duration: time.Duration - the wall-clock time between StartTime and EndTime
+type CommandResult struct {
+	Command   string        `json:"command" bson:"command" yaml:"command"`
+	Stdout    []byte        `json:"stdout" bson:"stdout" yaml:"stdout"`
+	Stderr    []byte        `json:"stderr" bson:"stderr" yaml:"stderr"`
+	ExitCode  int           `json:"exit_code" bson:"exit_code" yaml:"exit_code"`
+	Signal    string        `json:"signal" bson:"signal" yaml:"signal"`
+	StartTime time.Time     `json:"start_time" bson:"start_time" yaml:"start_time"`
+	EndTime   time.Time     `json:"end_time" bson:"end_time" yaml:"end_time"`
+	Duration  time.Duration `json:"duration" bson:"duration" yaml:"duration"`
+
+	StdoutSize      int64  `json:"stdout_size" bson:"stdout_size" yaml:"stdout_size"`
+	StderrSize      int64  `json:"stderr_size" bson:"stderr_size" yaml:"stderr_size"`
+	StdoutTruncated bool   `json:"stdout_truncated" bson:"stdout_truncated" yaml:"stdout_truncated"`
+	StderrTruncated bool   `json:"stderr_truncated" bson:"stderr_truncated" yaml:"stderr_truncated"`
+	StdoutFile      string `json:"stdout_file,omitempty" bson:"stdout_file,omitempty" yaml:"stdout_file,omitempty"`
+	StderrFile      string `json:"stderr_file,omitempty" bson:"stderr_file,omitempty" yaml:"stderr_file,omitempty"`
+
+	PeakMemory int64         `json:"peak_memory" bson:"peak_memory" yaml:"peak_memory"`
+	UserTime   time.Duration `json:"user_time" bson:"user_time" yaml:"user_time"`
+	SystemTime time.Duration `json:"system_time" bson:"system_time" yaml:"system_time"`
+	Cgroup     string        `json:"cgroup,omitempty" bson:"cgroup,omitempty" yaml:"cgroup,omitempty"`
+}
+
+// Returns true if the command exited with code 0.
+func (r *CommandResult) Success() bool {
+	return r.ExitCode == 0 && r.Signal == ""
+}
+
+// Returns the captured standard output as a string.
+func (r *CommandResult) StdoutString() string {
+	return string(r.Stdout)
+}
+
+// Returns the captured standard error as a string.
+func (r *CommandResult) StderrString() string {
+	return string(r.Stderr)
+}
+
+// Executes the specified command line in a shell and returns a CommandResult describing
+// the execution. On Windows the command is run using 'cmd', '/bin/sh' is used on Unix-based systems.
+//
+// The result is returned whenever the process could be started, even if it exited with a
+// non-zero code, so callers can inspect the exit code and stderr of failed commands.
+//
+// Example:
+//
+//	result, err := ExecuteCommand("ls -l")
+//	if err != nil {
+//	  fmt.Println("Exit code:", result.ExitCode)
+//	}
+//
+// Parameters:
+//
+//	cmdLine string: the command line to be executed
+//
+// Returns:
+//
+//	*CommandResult: the outcome of the execution, nil if the command could not be started
+//	error: an error if the command could not be started or did not exit successfully
+func ExecuteCommand(cmdLine string) (*CommandResult, error) {
+	return ExecuteCommandContext(context.Background(), cmdLine)
+}
+
+// Executes the specified command line in a shell like ExecuteCommand, but kills the
+// command's whole process group once the context is cancelled or its deadline expires.
+//
+// If the deadline expired the returned error wraps ErrCommandTimeout, if the context
+// was cancelled it wraps context.Canceled.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	result, err := ExecuteCommandContext(ctx, "make build")
+//	if errors.Is(err, ErrCommandTimeout) {
+//	  fmt.Println("Build took too long")
+//	}
+//
+// Parameters:
+//
+//	ctx context.Context: controls the lifetime of the command
+//	cmdLine string: the command line to be executed
+//
+// Returns:
+//
+//	*CommandResult: the outcome of the execution, nil if the command could not be started
+//	error: an error if the command could not be started, did not exit successfully or timed out
+func ExecuteCommandContext(ctx context.Context, cmdLine string) (*CommandResult, error) {
+	return NewShellCommand(cmdLine).Run(ctx)
+}
+
+// Builds an exec.Cmd running the command line through the platform shell.
+func shellCommand(ctx context.Context, cmdLine string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", cmdLine)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", cmdLine)
+}
+
+// Runs the command, capturing its output and exit status into a CommandResult.
+// The returned error is the one reported by exec.Cmd.Wait, so a non-zero exit yields an
+// *exec.ExitError alongside a populated result. If the context ended before the command
+// finished, the error describes the timeout or cancellation instead.
+func execute(ctx context.Context, c *Command) (*CommandResult, error) {
+	cmd := c.build(ctx)
+
+	stdout, err := newOutputCapture(c.outputLimit, StreamStdout)
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := newOutputCapture(c.outputLimit, StreamStderr)
+	if err != nil {
+		discardCapture(stdout)
+		return nil, err
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	var stdoutLines, stderrLines *lineWriter
+	if c.lineHandler != nil {
+		var mu sync.Mutex
+		stdoutLines = newLineWriter(StreamStdout, c.lineHandler, c.maxLineLength, &mu)
+		stderrLines = newLineWriter(StreamStderr, c.lineHandler, c.maxLineLength, &mu)
+		cmd.Stdout = io.MultiWriter(stdout, stdoutLines)
+		cmd.Stderr = io.MultiWriter(stderr, stderrLines)
+	}
+
+	cmd.WaitDelay = commandWaitDelay
+	configureProcessGroup(cmd)
+
+	if err := applyCredentials(c, cmd); err != nil {
+		discardCapture(stdout)
+		discardCapture(stderr)
+		return nil, err
+	}
+
+	resources, err := prepareResources(c, cmd)
+	if err != nil {
+		discardCapture(stdout)
+		discardCapture(stderr)
+		return nil, err
+	}
+
+	result := &CommandResult{Command: c.String(), ExitCode: -1}
+
+	result.StartTime = time.Now()
+	err = startCommand(c, cmd, resources)
+	if err != nil {
+		resources.release()
+		discardCapture(stdout)
+		discardCapture(stderr)
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	err = cmd.Wait()
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	outputErr := result.setOutput(stdout, stderr)
+
+	if c.lineHandler != nil {
+		stdoutLines.Flush()
+		stderrLines.Flush()
+	}
+
+	result.setExitStatus(cmd.ProcessState)
+	resources.finish(result)
+
+	if err != nil {
+		err = contextError(ctx, result, err)
+	}
+	if outputErr != nil {
+		err = errors.Join(err, outputErr)
+	}
+
+	return result, err
+}
+
+// Copies the captured output of both streams into the result and closes the captures.
+// Returns the first error of writing a spill file.
+func (r *CommandResult) setOutput(stdout, stderr outputCapture) error {
+	stdoutErr := r.setStdout(stdout)
+	stderrErr := r.setStderr(stderr)
+	if stdoutErr != nil {
+		return stdoutErr
+	}
+	return stderrErr
+}
+
+// Copies the captured standard output into the result and closes the capture.
+func (r *CommandResult) setStdout(stdout outputCapture) error {
+	r.Stdout = stdout.Bytes()
+	r.StdoutSize = stdout.Size()
+	r.StdoutTruncated = stdout.Truncated()
+	r.StdoutFile = stdout.File()
+	if err := stdout.Close(); err != nil {
+		return fmt.Errorf("stdout: %w", err)
+	}
+	return nil
+}
+
+// Copies the captured standard error into the result and closes the capture.
+func (r *CommandResult) setStderr(stderr outputCapture) error {
+	r.Stderr = stderr.Bytes()
+	r.StderrSize = stderr.Size()
+	r.StderrTruncated = stderr.Truncated()
+	r.StderrFile = stderr.File()
+	if err := stderr.Close(); err != nil {
+		return fmt.Errorf("stderr: %w", err)
+	}
+	return nil
+}
+
+// Copies the exit status and resource usage of a finished process into the result.
+func (r *CommandResult) setExitStatus(state *os.ProcessState) {
+	if state == nil {
+		return
+	}
+
+	r.ExitCode = state.ExitCode()
+	r.UserTime = state.UserTime()
+	r.SystemTime = state.SystemTime()
+	r.PeakMemory = maxRSS(state)
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		r.Signal = status.Signal().String()
+	}
+}
+
+// Replaces the error of a command that was killed because its context ended with one
+// describing the timeout or cancellation.
+func contextError(ctx context.Context, result *CommandResult, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%w after %s: %w", ErrCommandTimeout, result.Duration.Round(time.Millisecond), ctx.Err())
+	case context.Canceled:
+		return fmt.Errorf("command canceled: %w", ctx.Err())
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecuteCommand(t *testing.T) {
+	result, err := ExecuteCommand("echo out; echo err >&2; echo second")
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+
+	if result.StdoutString() != "out\nsecond\n" {
+		t.Errorf("Unexpected stdout. Expected: %q, Got: %q", "out\nsecond\n", result.Stdout)
+	}
+	if result.StderrString() != "err\n" {
+		t.Errorf("Unexpected stderr. Expected: %q, Got: %q", "err\n", result.Stderr)
+	}
+	if !result.Success() || result.ExitCode != 0 {
+		t.Errorf("Expected successful exit, got code %d", result.ExitCode)
+	}
+	if result.EndTime.Before(result.StartTime) || result.Duration != result.EndTime.Sub(result.StartTime) {
+		t.Errorf("Inconsistent timing: start %v, end %v, duration %v", result.StartTime, result.EndTime, result.Duration)
+	}
+}
+
+func TestExecuteCommandFailure(t *testing.T) {
+	result, err := ExecuteCommand("echo failing >&2; exit 3")
+	if err == nil {
+		t.Fatal("Expected an error for non-zero exit code")
+	}
+	if result == nil {
+		t.Fatal("Expected a result for a command that was started")
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("Unexpected exit code. Expected: 3, Got: %d", result.ExitCode)
+	}
+	if result.StderrString() != "failing\n" {
+		t.Errorf("Unexpected stderr. Expected: %q, Got: %q", "failing\n", result.Stderr)
+	}
+}
+
+func TestExecuteCommandSignal(t *testing.T) {
+	result, err := ExecuteCommand("kill -9 $$")
+	if err == nil {
+		t.Fatal("Expected an error for a killed command")
+	}
+	if result.ExitCode != -1 || result.Signal != "killed" {
+		t.Errorf("Expected termination by signal, got code %d and signal %q", result.ExitCode, result.Signal)
+	}
+	if result.Success() {
+		t.Error("Killed command must not be reported as successful")
+	}
+}
+
+func TestExecuteCommandContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The background sleep keeps stdout open, so Wait only returns early if the whole
+	// process group is killed.
+	start := time.Now()
+	result, err := ExecuteCommandContext(ctx, "sleep 30 & sleep 30; wait")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrCommandTimeout) {
+		t.Fatalf("Expected ErrCommandTimeout, got: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error to wrap context.DeadlineExceeded, got: %v", err)
+	}
+	if elapsed > 3*time.Second {
+		t.Errorf("Command was not killed in time, took %v", elapsed)
+	}
+	if result == nil || result.Signal == "" {
+		t.Errorf("Expected the result to report the killing signal, got %+v", result)
+	}
+}
+
+func TestRunCommandContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	_, err := RunCommandContext(ctx, "sleep 30")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+	if errors.Is(err, ErrCommandTimeout) {
+		t.Errorf("Cancellation must not be reported as timeout: %v", err)
+	}
+}
+
+func TestRunCommandGetOutputContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := RunCommandGetOutputContext(ctx, "printf 'a\\nb'")
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	if string(output) != "a\nb" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
@@ -0,0 +1,70 @@
+package system
+
+import (
+	"errors"
+)
+
+// Returned (wrapped) if running a command as another user was requested on a platform
+// without support for it.
+var ErrCredentialsUnsupported = errors.New("running commands as another user is not supported on this platform")
+
+// Environment variables that change how the dynamic linker, the C library or common
+// interpreters load code. They are removed from the environment of commands running as
+// another user, so a caller cannot smuggle code into the unprivileged process.
+var unsafeEnv = []string{
+	"BASH_ENV", "ENV", "SHELLOPTS", "BASHOPTS", "PS4", "IFS", "CDPATH",
+	"GCONV_PATH", "GETCONF_DIR", "HOSTALIASES", "LOCALDOMAIN", "LOCPATH",
+	"MALLOC_TRACE", "NIS_PATH", "NLSPATH", "RESOLV_HOST_CONF", "RES_OPTIONS",
+	"TMPDIR", "TZDIR",
+	"PYTHONPATH", "PYTHONSTARTUP", "PYTHONHOME", "PERL5LIB", "PERL5OPT", "PERLLIB",
+	"RUBYLIB", "RUBYOPT", "NODE_OPTIONS", "NODE_PATH",
+}
+
+// Describes the identity a command runs as. Users and groups can be given by name or
+// by numeric id. Only supported on Linux, and switching to another user requires the
+// current process to run as root or to have the CAP_SETUID and CAP_SETGID capabilities.
+//
+// Unless KeepEnv is set, variables like LD_PRELOAD or BASH_ENV are removed from the
+// environment of the command, and HOME, USER and LOGNAME describe the target user.
+// Variables set explicitly with WithEnv are always passed on.
+//
+// Fields:
+//   - User: string - the user name or uid to run as
+//   - Group: string - the group name or gid to run as, the primary group of the user if empty
+//   - Groups: []string - the supplementary groups, the groups the user is a member of if nil;
+//     an empty, non-nil slice drops all supplementary groups
+//   - KeepEnv: bool - keep the environment unchanged instead of removing unsafe variables
+//   - NoNewPrivileges: bool - prevent the command and its children from gaining privileges,
+//     e.g. through setuid binaries like sudo
+type Credentials struct {
+	User            string
+	Group           string
+	Groups          []string
+	KeepEnv         bool
+	NoNewPrivileges bool
+}
+
+// Runs the command with the given credentials instead of those of the current process.
+//
+// Example usage:
+//
+//	result, err := NewCommand("git", "pull").
+//	  WithDir("/srv/app").
+//	  WithCredentials(Credentials{User: "deploy", NoNewPrivileges: true}).
+//	  Run(ctx)
+//	if errors.Is(err, os.ErrPermission) {
+//	  fmt.Println("The agent has to run as root to switch users")
+//	}
+func (c *Command) WithCredentials(credentials Credentials) *Command {
+	if credentials.Groups != nil {
+		credentials.Groups = append([]string{}, credentials.Groups...)
+	}
+	c.credentials = &credentials
+	return c
+}
+
+// Runs the command as the named user, with the user's primary and supplementary groups.
+// Shorthand for WithCredentials(Credentials{User: name}).
+func (c *Command) AsUser(name string) *Command {
+	return c.WithCredentials(Credentials{User: name})
+}
@@ -0,0 +1,202 @@
+//go:build linux
+
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// The prctl option setting the no_new_privs flag, missing from the syscall package.
+const prSetNoNewPrivs = 38
+
+// The numeric identity a command runs as.
+type resolvedCredentials struct {
+	username string
+	home     string
+	uid      uint32
+	gid      uint32
+	groups   []uint32
+}
+
+// Switches the exec.Cmd to the credentials of the command, if it has any.
+func applyCredentials(c *Command, cmd *exec.Cmd) error {
+	if c.credentials == nil {
+		return nil
+	}
+
+	id, err := resolveCredentials(c.credentials)
+	if err != nil {
+		return err
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: id.uid, Gid: id.gid, Groups: id.groups}
+	cmd.Env = c.credentialsEnviron(cmd.Env, id.username, id.home)
+	return nil
+}
+
+// Starts the command, switching off privilege escalation first if requested.
+// A permission error caused by the missing right to switch users is reported as such.
+func startCommand(c *Command, cmd *exec.Cmd, resources *resourceControl) error {
+	var err error
+	if c.credentials != nil && c.credentials.NoNewPrivileges {
+		err = startWithoutNewPrivileges(cmd, resources)
+	} else {
+		err = resources.start(cmd)
+	}
+
+	if err != nil && c.credentials != nil && errors.Is(err, syscall.EPERM) {
+		return fmt.Errorf("not permitted to run as user %s, root or CAP_SETUID and CAP_SETGID are required: %w",
+			c.credentials.User, err)
+	}
+	return err
+}
+
+// Starts the command from a dedicated OS thread with the no_new_privs flag set.
+// Children inherit the flag from the thread that forks them, and it can never be cleared,
+// so the thread is not unlocked again and the runtime discards it once the goroutine ends.
+func startWithoutNewPrivileges(cmd *exec.Cmd, resources *resourceControl) error {
+	done := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0)
+		if errno != 0 {
+			done <- fmt.Errorf("failed to set no_new_privs: %w", errno)
+			return
+		}
+		done <- resources.start(cmd)
+	}()
+	return <-done
+}
+
+// Resolves user and group names to numeric ids using the user and group databases.
+func resolveCredentials(credentials *Credentials) (*resolvedCredentials, error) {
+	if credentials.User == "" {
+		return nil, fmt.Errorf("no user given to run the command as")
+	}
+
+	id := &resolvedCredentials{username: credentials.User, home: "/"}
+
+	u, err := lookupUser(credentials.User)
+	if err != nil {
+		return nil, err
+	}
+	if u != nil {
+		id.username = u.Username
+		if u.HomeDir != "" {
+			id.home = u.HomeDir
+		}
+		id.uid, _ = parseID(u.Uid)
+		id.gid, _ = parseID(u.Gid)
+	} else {
+		// Numeric ids without a database entry are used as they are
+		id.uid, _ = parseID(credentials.User)
+		id.gid = id.uid
+	}
+
+	if credentials.Group != "" {
+		if id.gid, err = lookupGroup(credentials.Group); err != nil {
+			return nil, err
+		}
+	}
+
+	if credentials.Groups != nil {
+		id.groups = make([]uint32, 0, len(credentials.Groups))
+		for _, name := range credentials.Groups {
+			gid, err := lookupGroup(name)
+			if err != nil {
+				return nil, err
+			}
+			id.groups = append(id.groups, gid)
+		}
+	} else if u != nil {
+		groupIDs, err := u.GroupIds()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up the groups of user %s: %w", u.Username, err)
+		}
+		for _, groupID := range groupIDs {
+			if gid, err := parseID(groupID); err == nil {
+				id.groups = append(id.groups, gid)
+			}
+		}
+	}
+
+	return id, nil
+}
+
+// Looks up a user by name or uid. Returns nil without an error for a uid that has no
+// entry in the user database.
+func lookupUser(name string) (*user.User, error) {
+	if _, err := parseID(name); err == nil {
+		u, err := user.LookupId(name)
+		if errors.As(err, new(user.UnknownUserIdError)) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up user %s: %w", name, err)
+		}
+		return u, nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user %s: %w", name, err)
+	}
+	return u, nil
+}
+
+// Looks up a group by name or gid. A gid without an entry in the group database is used as it is.
+func lookupGroup(name string) (uint32, error) {
+	if gid, err := parseID(name); err == nil {
+		return gid, nil
+	}
+
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up group %s: %w", name, err)
+	}
+	return parseID(g.Gid)
+}
+
+func parseID(id string) (uint32, error) {
+	value, err := strconv.ParseUint(id, 10, 32)
+	return uint32(value), err
+}
+
+// Computes the environment of a command running as another user, describing that user
+// and without unsafe variables inherited from the current process.
+func (c *Command) credentialsEnviron(env []string, username, home string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+
+	if !c.credentials.KeepEnv {
+		filtered := env[:0]
+		for _, entry := range env {
+			key, _, _ := strings.Cut(entry, "=")
+			if strings.HasPrefix(key, "LD_") || containsString(unsafeEnv, key) {
+				continue
+			}
+			filtered = append(filtered, entry)
+		}
+		env = filtered
+	}
+
+	identity := []string{"HOME=" + home, "USER=" + username, "LOGNAME=" + username}
+	for _, entry := range append(identity, c.env...) {
+		key, _, _ := strings.Cut(entry, "=")
+		env = append(removeEnv(env, key), entry)
+	}
+	return env
+}
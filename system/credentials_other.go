@@ -0,0 +1,18 @@
+//go:build !linux
+
+package system
+
+import (
+	"os/exec"
+)
+
+func applyCredentials(c *Command, cmd *exec.Cmd) error {
+	if c.credentials == nil {
+		return nil
+	}
+	return ErrCredentialsUnsupported
+}
+
+func startCommand(c *Command, cmd *exec.Cmd, resources *resourceControl) error {
+	return resources.start(cmd)
+}
@@ -0,0 +1,110 @@
+//go:build linux
+
+package system
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/user"
+	"strings"
+	"testing"
+)
+
+func TestResolveCredentials(t *testing.T) {
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("No user nobody in the user database")
+	}
+
+	id, err := resolveCredentials(&Credentials{User: "nobody", Groups: []string{}})
+	if err != nil {
+		t.Fatalf("Failed to resolve credentials: %v", err)
+	}
+	if uid, _ := parseID(nobody.Uid); id.uid != uid || id.username != "nobody" || len(id.groups) != 0 {
+		t.Errorf("Unexpected credentials for nobody: %+v", id)
+	}
+
+	id, err = resolveCredentials(&Credentials{User: nobody.Uid, Group: "4242", Groups: []string{"4243"}})
+	if err != nil {
+		t.Fatalf("Failed to resolve numeric credentials: %v", err)
+	}
+	if id.username != "nobody" || id.gid != 4242 || len(id.groups) != 1 || id.groups[0] != 4243 {
+		t.Errorf("Unexpected credentials for numeric ids: %+v", id)
+	}
+
+	id, err = resolveCredentials(&Credentials{User: "3999999"})
+	if err != nil {
+		t.Fatalf("Failed to resolve unknown uid: %v", err)
+	}
+	if id.uid != 3999999 || id.gid != 3999999 || id.home != "/" {
+		t.Errorf("Unexpected credentials for unknown uid: %+v", id)
+	}
+
+	if _, err := resolveCredentials(&Credentials{User: "system-test-missing-user"}); err == nil {
+		t.Error("Expected an error for an unknown user name")
+	}
+	if _, err := resolveCredentials(&Credentials{User: "nobody", Group: "system-test-missing-group"}); err == nil {
+		t.Error("Expected an error for an unknown group name")
+	}
+}
+
+func TestCommandCredentials(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Switching users requires root")
+	}
+	t.Setenv("LD_PRELOAD", "/tmp/system-test-evil.so")
+	t.Setenv("BASH_ENV", "/tmp/system-test-evil.sh")
+
+	result, err := NewCommand("sh", "-c", `id -u; id -G; echo "$USER ${LD_PRELOAD-unset} ${BASH_ENV-unset} $EXTRA"`).
+		WithEnv("EXTRA", "kept").
+		WithCredentials(Credentials{User: "65534", Group: "65534", Groups: []string{}}).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(result.StdoutString()), "\n")
+	if len(lines) != 3 || lines[0] != "65534" || lines[1] != "65534" || !strings.HasSuffix(lines[2], " unset unset kept") {
+		t.Errorf("Unexpected identity or environment, got %q", result.Stdout)
+	}
+}
+
+func TestCommandNoNewPrivileges(t *testing.T) {
+	self, err := user.Current()
+	if err != nil {
+		t.Skip("Cannot look up the current user")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("Setting supplementary groups requires root")
+	}
+
+	result, err := NewCommand("grep", "NoNewPrivs", "/proc/self/status").
+		WithCredentials(Credentials{User: self.Uid, NoNewPrivileges: true}).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	if fields := strings.Fields(result.StdoutString()); len(fields) != 2 || fields[1] != "1" {
+		t.Errorf("Expected no_new_privs to be set, got %q", result.Stdout)
+	}
+
+	result, err = NewCommand("grep", "NoNewPrivs", "/proc/self/status").Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	if fields := strings.Fields(result.StdoutString()); len(fields) != 2 || fields[1] != "0" {
+		t.Errorf("The flag leaked into later commands, got %q", result.Stdout)
+	}
+}
+
+func TestCommandCredentialsPermission(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Root may switch to any user")
+	}
+
+	_, err := NewCommand("true").AsUser("0").Run(context.Background())
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("Expected a permission error, got: %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+package system
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Computes the activation times of a scheduled job.
+type Schedule interface {
+	// Returns the first activation strictly after the given time, the zero time if there is none.
+	Next(after time.Time) time.Time
+}
+
+// A schedule defined by a cron expression.
+type CronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool
+	location                              *time.Location
+}
+
+// A schedule activating at a fixed interval.
+type IntervalSchedule struct {
+	Interval time.Duration
+}
+
+// The allowed values and names of a cron field.
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronSeconds = cronField{name: "second", min: 0, max: 59}
+	cronMinutes = cronField{name: "minute", min: 0, max: 59}
+	cronHours   = cronField{name: "hour", min: 0, max: 23}
+	cronDays    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonths  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronWeekdays = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parses a cron expression into a Schedule.
+//
+// The expression has five fields (minute, hour, day of month, month, day of week) or six
+// fields with a leading second. Fields accept '*', lists, ranges and steps like "1-5",
+// "*/15" or "MON-FRI", month and weekday names, and '?' as an alias of '*'. Like classic
+// cron, a job runs when either the day of month or the day of week matches if both are
+// restricted. The descriptors @yearly, @monthly, @weekly, @daily, @hourly and
+// "@every <duration>" are supported as well.
+//
+// A "CRON_TZ=<zone>" or "TZ=<zone>" prefix evaluates the schedule in that time zone,
+// otherwise the location of the time passed to Next is used.
+//
+// Parameters:
+//   - spec: string - the cron expression
+//
+// Returns:
+//   - Schedule: the parsed schedule
+//   - error: if the expression is invalid or the time zone is unknown
+//
+// Example usage:
+//
+//	schedule, err := ParseSchedule("CRON_TZ=Europe/Berlin 30 2 * * MON-FRI")
+//	next := schedule.Next(time.Now())
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	var location *time.Location
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		zone, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(zone, "=")
+		var err error
+		if location, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("invalid time zone in schedule %q: %w", spec, err)
+		}
+		spec = strings.TrimSpace(rest)
+	}
+
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		duration, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid interval in schedule %q", spec)
+		}
+		return IntervalSchedule{Interval: duration}, nil
+	}
+	if strings.HasPrefix(spec, "@") {
+		expanded, ok := cronDescriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unknown schedule descriptor %q", spec)
+		}
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 or 6 fields, got %d", spec, len(fields))
+	}
+
+	schedule := &CronSchedule{location: location}
+	var err error
+	for i, target := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&schedule.second, cronSeconds},
+		{&schedule.minute, cronMinutes},
+		{&schedule.hour, cronHours},
+		{&schedule.dom, cronDays},
+		{&schedule.month, cronMonths},
+		{&schedule.dow, cronWeekdays},
+	} {
+		if *target.bits, err = parseCronField(fields[i], target.field); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+	}
+
+	// Sunday may be written as 7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow = schedule.dow&^(1<<7) | 1
+	}
+	schedule.domStar = strings.HasPrefix(fields[3], "*") || fields[3] == "?"
+	schedule.dowStar = strings.HasPrefix(fields[5], "*") || fields[5] == "?"
+	return schedule, nil
+}
+
+// Returns the time one interval after the given one.
+func (s IntervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.Interval)
+}
+
+// Returns the first time after the given one matching the cron expression, in the
+// location of the given time. Returns the zero time if the expression never matches
+// within the next five years, e.g. for February 30th.
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	location := s.location
+	if location == nil {
+		location = after.Location()
+	}
+	original := after.Location()
+
+	t := after.In(location).Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + 5
+	truncated := false
+
+wrap:
+	for t.Year() <= limit {
+		for s.month&(1<<uint(t.Month())) == 0 {
+			if !truncated {
+				truncated = true
+				t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, location)
+			}
+			t = t.AddDate(0, 1, 0)
+			if t.Month() == time.January {
+				continue wrap
+			}
+		}
+
+		for !s.dayMatches(t) {
+			if !truncated {
+				truncated = true
+				t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+			}
+			t = t.AddDate(0, 0, 1)
+			// Midnight does not exist on some DST transitions, the day starts at 1am then
+			if t.Hour() != 0 {
+				t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+			}
+			if t.Day() == 1 {
+				continue wrap
+			}
+		}
+
+		for s.hour&(1<<uint(t.Hour())) == 0 {
+			if !truncated {
+				truncated = true
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, location)
+			}
+			t = t.Add(time.Hour)
+			if t.Hour() == 0 {
+				continue wrap
+			}
+		}
+
+		for s.minute&(1<<uint(t.Minute())) == 0 {
+			if !truncated {
+				truncated = true
+				t = t.Truncate(time.Minute)
+			}
+			t = t.Add(time.Minute)
+			if t.Minute() == 0 {
+				continue wrap
+			}
+		}
+
+		for s.second&(1<<uint(t.Second())) == 0 {
+			truncated = true
+			t = t.Add(time.Second)
+			if t.Second() == 0 {
+				continue wrap
+			}
+		}
+
+		return t.In(original)
+	}
+	return time.Time{}
+}
+
+// Applies the classic cron rule: if both day fields are restricted, either may match.
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Parses a single cron field into a bit set of the allowed values.
+func parseCronField(spec string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(spec, ",") {
+		rangeSpec, stepSpec, hasStep := strings.Cut(part, "/")
+
+		start, end := field.min, field.max
+		switch {
+		case rangeSpec == "*" || rangeSpec == "?":
+		case strings.Contains(rangeSpec, "-"):
+			first, last, _ := strings.Cut(rangeSpec, "-")
+			var err error
+			if start, err = field.value(first); err != nil {
+				return 0, err
+			}
+			if end, err = field.value(last); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if start, err = field.value(rangeSpec); err != nil {
+				return 0, err
+			}
+			// "5/15" means from 5 to the end in steps of 15
+			if !hasStep {
+				end = start
+			}
+		}
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepSpec)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepSpec, field.name)
+			}
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid range %q in %s field", rangeSpec, field.name)
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// Parses a single value of the field, either a number or a name.
+func (f cronField) value(spec string) (int, error) {
+	if value, ok := f.names[strings.ToLower(spec)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(spec)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, expected %d-%d", spec, f.name, f.min, f.max)
+	}
+	return value, nil
+}
@@ -0,0 +1,109 @@
+package system
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	tests := []struct {
+		spec     string
+		after    string
+		expected string
+	}{
+		{"* * * * *", "2024-03-10T10:15:30Z", "2024-03-10T10:16:00Z"},
+		{"*/15 * * * * *", "2024-03-10T10:15:31Z", "2024-03-10T10:15:45Z"},
+		{"0 3 * * *", "2024-03-10T03:00:00Z", "2024-03-11T03:00:00Z"},
+		{"30 2 * * MON-FRI", "2024-03-08T03:00:00Z", "2024-03-11T02:30:00Z"},
+		{"0 0 1,15 * *", "2024-01-15T00:00:00Z", "2024-02-01T00:00:00Z"},
+		{"0 12 * FEB *", "2024-03-01T00:00:00Z", "2025-02-01T12:00:00Z"},
+		{"0 0 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"0 0 * * 7", "2024-03-10T00:00:00Z", "2024-03-17T00:00:00Z"},
+		{"5/20 * * * *", "2024-03-10T10:26:00Z", "2024-03-10T10:45:00Z"},
+		{"0 0 13 * 5", "2024-03-10T00:00:00Z", "2024-03-13T00:00:00Z"},
+		{"0 0 ? * fri", "2024-03-10T00:00:00Z", "2024-03-15T00:00:00Z"},
+		{"@daily", "2024-12-31T23:59:59Z", "2025-01-01T00:00:00Z"},
+		{"@hourly", "2024-03-10T10:00:00Z", "2024-03-10T11:00:00Z"},
+		{"@weekly", "2024-03-10T00:00:00Z", "2024-03-17T00:00:00Z"},
+		{"@monthly", "2024-03-10T00:00:00Z", "2024-04-01T00:00:00Z"},
+		{"@yearly", "2024-03-10T00:00:00Z", "2025-01-01T00:00:00Z"},
+		{"@every 90m", "2024-03-10T10:00:00Z", "2024-03-10T11:30:00Z"},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseSchedule(test.spec)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", test.spec, err)
+			continue
+		}
+		after, _ := time.Parse(time.RFC3339, test.after)
+		expected, _ := time.Parse(time.RFC3339, test.expected)
+		if next := schedule.Next(after); !next.Equal(expected) {
+			t.Errorf("Unexpected next time for %q after %s. Expected: %s, Got: %s", test.spec, test.after, expected, next)
+		}
+	}
+}
+
+func TestParseScheduleTimeZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone database not available: %v", err)
+	}
+
+	schedule, err := ParseSchedule("CRON_TZ=America/New_York 0 9 * * *")
+	if err != nil {
+		t.Fatalf("Failed to parse schedule: %v", err)
+	}
+	next := schedule.Next(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	if expected := time.Date(2024, 7, 1, 13, 0, 0, 0, time.UTC); !next.Equal(expected) || next.Location() != time.UTC {
+		t.Errorf("Unexpected next time. Expected: %s, Got: %s", expected, next)
+	}
+
+	// Without a prefix the location of the given time is used
+	schedule, _ = ParseSchedule("0 9 * * *")
+	next = schedule.Next(time.Date(2024, 7, 1, 0, 0, 0, 0, newYork))
+	if expected := time.Date(2024, 7, 1, 9, 0, 0, 0, newYork); !next.Equal(expected) {
+		t.Errorf("Unexpected next time. Expected: %s, Got: %s", expected, next)
+	}
+
+	// 02:30 does not exist on the day clocks spring forward
+	schedule, _ = ParseSchedule("30 2 * * *")
+	next = schedule.Next(time.Date(2024, 3, 10, 0, 0, 0, 0, newYork))
+	if expected := time.Date(2024, 3, 11, 2, 30, 0, 0, newYork); !next.Equal(expected) {
+		t.Errorf("Unexpected next time across DST. Expected: %s, Got: %s", expected, next)
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@fortnightly",
+		"@every soon",
+		"@every -5m",
+		"CRON_TZ=Mars/Olympus 0 0 * * *",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestCronScheduleNeverMatches(t *testing.T) {
+	schedule, err := ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("Failed to parse schedule: %v", err)
+	}
+	if next := schedule.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !next.IsZero() {
+		t.Errorf("Expected no activation for February 30th, got %s", next)
+	}
+}
@@ -0,0 +1,435 @@
+package system
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tpasson/sw-go-utility-lib/networking"
+)
+
+// Returned (wrapped in a *FieldError) by LoadEnv for required variables that are not set.
+var ErrMissingVariable = errors.New("required variable is not set")
+
+// A size in bytes, parsed from values like "512", "64KB" or "1.5GiB".
+type ByteSize int64
+
+// Describes a field LoadEnv could not populate. It wraps the cause, e.g. ErrMissingVariable.
+// The value of the variable is never part of the error, as it may be a secret.
+//
+// Fields:
+//   - Field: string - the path of the struct field, e.g. "Database.Port"
+//   - Variable: string - the environment variable of the field
+//   - Err: error - the cause
+type FieldError struct {
+	Field    string
+	Variable string
+	Err      error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s (%s): %v", e.Variable, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Populates structs from environment variables, configured by struct tags:
+//
+//   - env:"NAME" - the variable of the field, fields without the tag are ignored; on a
+//     nested struct the tag is the prefix of the variables of its fields
+//   - default:"value" - the value used if the variable is not set
+//   - required:"true" - reports an error if the variable is not set and has no default
+//   - separator:";" - splits the value of slice fields, "," by default
+//
+// Supported field types are strings, booleans, integers, floats, time.Duration, ByteSize,
+// net.IP and slices of those. IPv4 addresses are validated with the networking package.
+// If NAME is not set but NAME_FILE is, the value is read from the file named by NAME_FILE,
+// which is how Docker and Kubernetes provide secrets.
+//
+// Fields:
+//   - LookupEnv: func(string) (string, bool) - looks up variables, os.LookupEnv if nil
+//   - Files: []string - .env files consulted for variables the environment does not set,
+//     later files take precedence; files that do not exist are skipped
+//
+// Example usage:
+//
+//	type Config struct {
+//	  Listen   net.IP        `env:"LISTEN" default:"0.0.0.0"`
+//	  Port     int           `env:"PORT" default:"8080"`
+//	  Timeout  time.Duration `env:"TIMEOUT" default:"30s"`
+//	  MaxBody  ByteSize      `env:"MAX_BODY" default:"10MiB"`
+//	  Peers    []string      `env:"PEERS" separator:";"`
+//	  Database struct {
+//	    URL      string `env:"URL" required:"true"`
+//	    Password string `env:"PASSWORD" required:"true"`
+//	  } `env:"DB_"`
+//	}
+//
+//	var config Config
+//	if err := (EnvLoader{Files: []string{".env"}}).Load(&config); err != nil {
+//	  log.Fatalf("invalid configuration:\n%v", err)
+//	}
+type EnvLoader struct {
+	LookupEnv func(key string) (string, bool)
+	Files     []string
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+	ipType       = reflect.TypeOf(net.IP{})
+)
+
+// Populates the struct from the process environment and the given .env files.
+// Shorthand for EnvLoader{Files: files}.Load(target).
+func LoadEnv(target any, files ...string) error {
+	return EnvLoader{Files: files}.Load(target)
+}
+
+// Populates the struct target points to. All fields are processed even if some fail.
+//
+// Parameters:
+//   - target: any - a pointer to the struct to populate
+//
+// Returns:
+//   - error: the joined *FieldError of every field that is missing or invalid, or an error
+//     if target is not a pointer to a struct or a .env file could not be read
+func (l EnvLoader) Load(target any) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("configuration target must be a pointer to a struct, got %T", target)
+	}
+
+	dotEnv := make(map[string]string)
+	for _, file := range l.Files {
+		values, err := ReadDotEnv(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			dotEnv[key] = value
+		}
+	}
+
+	lookup := func(key string) (string, bool) {
+		lookupEnv := l.LookupEnv
+		if lookupEnv == nil {
+			lookupEnv = os.LookupEnv
+		}
+		if value, ok := lookupEnv(key); ok {
+			return value, true
+		}
+		value, ok := dotEnv[key]
+		return value, ok
+	}
+
+	var errs []error
+	loadEnvStruct(value.Elem(), "", "", lookup, &errs)
+	return errors.Join(errs...)
+}
+
+// Parses a size like "512", "512B", "64KB", "64K" or "1.5GiB". Units without 'i' are
+// decimal (KB = 1000 bytes), units with 'i' are binary (KiB = 1024 bytes). Units are case
+// insensitive and may be separated from the number by a space.
+//
+// Parameters:
+//   - text: string - the size
+//
+// Returns:
+//   - ByteSize: the size in bytes
+//   - error: if the size is malformed, negative or too large
+func ParseByteSize(text string) (ByteSize, error) {
+	text = strings.TrimSpace(text)
+	end := 0
+	for end < len(text) && (text[end] >= '0' && text[end] <= '9' || text[end] == '.') {
+		end++
+	}
+	number, err := strconv.ParseFloat(text[:end], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+
+	unit := strings.ToLower(strings.TrimSpace(text[end:]))
+	multiplier := 1.0
+	if unit != "" && unit != "b" {
+		unit = strings.TrimSuffix(unit, "b")
+		base := 1000.0
+		if strings.HasSuffix(unit, "i") {
+			base = 1024
+			unit = strings.TrimSuffix(unit, "i")
+		}
+		exponent := strings.Index("kmgtpe", unit)
+		if len(unit) != 1 || exponent < 0 {
+			return 0, fmt.Errorf("invalid size unit in %q", text)
+		}
+		multiplier = math.Pow(base, float64(exponent+1))
+	}
+
+	bytes := math.Round(number * multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", text)
+	}
+	return ByteSize(bytes), nil
+}
+
+// Reads the variables of a .env file, see ParseDotEnv.
+func ReadDotEnv(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values, err := ParseDotEnv(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// Parses the KEY=VALUE lines of a .env file. Empty lines and lines starting with '#' are
+// ignored, as is an "export " prefix. Values may be single quoted (taken literally), double
+// quoted (supporting \n, \t, \" and \\) or unquoted, where " #" starts a comment.
+//
+// Parameters:
+//   - r: io.Reader - the content of the file
+//
+// Returns:
+//   - map[string]string: the variables
+//   - error: if a line is malformed
+func ParseDotEnv(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t\"'") {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", number)
+		}
+
+		value, err := parseDotEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Parses a value of a .env file, removing quotes and comments.
+func parseDotEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	switch value[0] {
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", errors.New("unterminated single quote")
+		}
+		return value[1 : end+1], checkDotEnvRest(value[end+2:])
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch c := value[i]; {
+			case c == '"':
+				return b.String(), checkDotEnvRest(value[i+1:])
+			case c == '\\' && i+1 < len(value):
+				i++
+				switch value[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				default:
+					b.WriteByte(value[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", errors.New("unterminated double quote")
+	}
+
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = value[:comment]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// Verifies that only a comment follows a quoted value.
+func checkDotEnvRest(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return errors.New("unexpected characters after quoted value")
+	}
+	return nil
+}
+
+// Populates the fields of the struct, appending a *FieldError for every failing field.
+func loadEnvStruct(value reflect.Value, prefix, path string, lookup func(string) (string, bool), errs *[]error) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, tagged := field.Tag.Lookup("env")
+
+		if field.Type.Kind() == reflect.Struct {
+			loadEnvStruct(value.Field(i), prefix+name, path+field.Name+".", lookup, errs)
+			continue
+		}
+		if !tagged {
+			continue
+		}
+
+		fieldErr := &FieldError{Field: path + field.Name, Variable: prefix + name}
+		raw, ok, err := lookupEnvValue(fieldErr.Variable, lookup)
+		if err != nil {
+			fieldErr.Err = err
+			*errs = append(*errs, fieldErr)
+			continue
+		}
+		if !ok {
+			raw, ok = field.Tag.Lookup("default")
+		}
+		if !ok {
+			if field.Tag.Get("required") == "true" {
+				fieldErr.Err = ErrMissingVariable
+				*errs = append(*errs, fieldErr)
+			}
+			continue
+		}
+
+		separator := field.Tag.Get("separator")
+		if separator == "" {
+			separator = ","
+		}
+		if err := setEnvValue(value.Field(i), raw, separator); err != nil {
+			fieldErr.Err = err
+			*errs = append(*errs, fieldErr)
+		}
+	}
+}
+
+// Returns the value of the variable, or the content of the file named by NAME_FILE.
+func lookupEnvValue(name string, lookup func(string) (string, bool)) (string, bool, error) {
+	value, ok := lookup(name)
+	file, fileOK := lookup(name + "_FILE")
+	switch {
+	case ok && fileOK:
+		return "", false, fmt.Errorf("both %s and %s_FILE are set", name, name)
+	case fileOK:
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), true, nil
+	}
+	return value, ok, nil
+}
+
+// Parses the text into the field. The errors do not contain the text, it may be a secret.
+func setEnvValue(field reflect.Value, text string, separator string) error {
+	switch field.Type() {
+	case durationType:
+		duration, err := time.ParseDuration(strings.TrimSpace(text))
+		if err != nil {
+			return errors.New("invalid duration, expected a value like 30s or 5m")
+		}
+		field.SetInt(int64(duration))
+		return nil
+	case byteSizeType:
+		size, err := ParseByteSize(text)
+		if err != nil {
+			return errors.New("invalid size, expected a value like 512KB or 1GiB")
+		}
+		field.SetInt(int64(size))
+		return nil
+	case ipType:
+		text = strings.TrimSpace(text)
+		var ip net.IP
+		if strings.Contains(text, ":") {
+			ip = net.ParseIP(text)
+		} else if networking.IsValidIPv4(text) {
+			ip = net.ParseIP(text)
+		}
+		if ip == nil {
+			return errors.New("invalid IP address")
+		}
+		field.Set(reflect.ValueOf(ip))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return errors.New("invalid boolean, expected true or false")
+		}
+		field.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// Base 10 only, a leading zero as in "010" must not turn the value into an octal number
+		value, err := strconv.ParseInt(strings.TrimSpace(text), 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s", field.Type())
+		}
+		field.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := strconv.ParseUint(strings.TrimSpace(text), 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s", field.Type())
+		}
+		field.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(strings.TrimSpace(text), field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s", field.Type())
+		}
+		field.SetFloat(value)
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), 0, 0)
+		if strings.TrimSpace(text) != "" {
+			for i, part := range strings.Split(text, separator) {
+				element := reflect.New(field.Type().Elem()).Elem()
+				if err := setEnvValue(element, strings.TrimSpace(part), separator); err != nil {
+					return fmt.Errorf("element %d: %w", i, err)
+				}
+				slice = reflect.Append(slice, element)
+			}
+		}
+		field.Set(slice)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
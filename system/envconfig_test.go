@@ -0,0 +1,234 @@
+package system
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testServiceConfig struct {
+	Name     string        `env:"NAME" default:"api"`
+	Listen   net.IP        `env:"LISTEN" default:"0.0.0.0"`
+	Port     uint16        `env:"PORT" required:"true"`
+	Debug    bool          `env:"DEBUG"`
+	Ratio    float64       `env:"RATIO" default:"0.5"`
+	Timeout  time.Duration `env:"TIMEOUT" default:"30s"`
+	MaxBody  ByteSize      `env:"MAX_BODY" default:"10MiB"`
+	Peers    []string      `env:"PEERS" separator:";"`
+	Retries  []int         `env:"RETRIES" default:"1, 2, 4"`
+	Ignored  string
+	internal string `env:"INTERNAL"`
+
+	Database struct {
+		URL      string `env:"URL" required:"true"`
+		Password string `env:"PASSWORD" required:"true"`
+	} `env:"DB_"`
+}
+
+func lookupMap(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestEnvLoader(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "db_password")
+	writeFixture(t, dir, map[string]string{
+		"db_password": "s3cr3t\n",
+		".env": `# local development
+export DB_URL="postgres://localhost/app?sslmode=disable"
+PORT=9000
+NAME=from-file # overridden by the environment
+PEERS='10.0.0.1:7000;10.0.0.2:7000'
+`,
+	})
+
+	var config testServiceConfig
+	loader := EnvLoader{
+		LookupEnv: lookupMap(map[string]string{
+			"NAME":             "billing",
+			"LISTEN":           "127.0.0.1",
+			"DEBUG":            "true",
+			"MAX_BODY":         "1.5 GiB",
+			"DB_PASSWORD_FILE": secret,
+			"INTERNAL":         "x",
+			"Ignored":          "x",
+		}),
+		Files: []string{filepath.Join(dir, ".env"), filepath.Join(dir, "missing.env")},
+	}
+	if err := loader.Load(&config); err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if config.Name != "billing" || !config.Listen.Equal(net.ParseIP("127.0.0.1")) || config.Port != 9000 || !config.Debug {
+		t.Errorf("Unexpected scalar values: %+v", config)
+	}
+	if config.Ratio != 0.5 || config.Timeout != 30*time.Second || config.MaxBody != 1610612736 {
+		t.Errorf("Unexpected defaults: %+v", config)
+	}
+	if strings.Join(config.Peers, " ") != "10.0.0.1:7000 10.0.0.2:7000" || len(config.Retries) != 3 || config.Retries[2] != 4 {
+		t.Errorf("Unexpected slices: %v %v", config.Peers, config.Retries)
+	}
+	if config.Database.URL != "postgres://localhost/app?sslmode=disable" || config.Database.Password != "s3cr3t" {
+		t.Errorf("Unexpected database settings: %+v", config.Database)
+	}
+	if config.Ignored != "" || config.internal != "" {
+		t.Error("Expected untagged and unexported fields to be ignored")
+	}
+}
+
+func TestEnvLoaderDecimalIntegers(t *testing.T) {
+	var config struct {
+		Port  int  `env:"PORT"`
+		Umask uint `env:"UMASK"`
+	}
+	err := EnvLoader{LookupEnv: lookupMap(map[string]string{"PORT": "010", "UMASK": "08"})}.Load(&config)
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if config.Port != 10 || config.Umask != 8 {
+		t.Errorf("Expected leading zeros to be ignored, got %d and %d", config.Port, config.Umask)
+	}
+}
+
+func TestEnvLoaderIPAddresses(t *testing.T) {
+	var config struct {
+		Listen  net.IP `env:"LISTEN"`
+		Gateway net.IP `env:"GATEWAY"`
+	}
+	err := EnvLoader{LookupEnv: lookupMap(map[string]string{"LISTEN": "::1", "GATEWAY": " 10.0.0.1 "})}.Load(&config)
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if !config.Listen.Equal(net.IPv6loopback) || !config.Gateway.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Unexpected addresses: %s and %s", config.Listen, config.Gateway)
+	}
+
+	err = EnvLoader{LookupEnv: lookupMap(map[string]string{"LISTEN": "10.0.0"})}.Load(&config)
+	if err == nil || !strings.Contains(err.Error(), "invalid IP address") {
+		t.Errorf("Expected an incomplete IPv4 address to be rejected, got: %v", err)
+	}
+}
+
+func TestEnvLoaderErrors(t *testing.T) {
+	var config testServiceConfig
+	err := EnvLoader{LookupEnv: lookupMap(map[string]string{
+		"LISTEN":           "300.1.1.1",
+		"PORT":             "70000",
+		"TIMEOUT":          "soon",
+		"RETRIES":          "1,two",
+		"MAX_BODY":         "10 parsecs",
+		"DB_PASSWORD":      "hunter2",
+		"DB_PASSWORD_FILE": "/run/secrets/db",
+	})}.Load(&config)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	message := err.Error()
+	for _, expected := range []string{
+		"LISTEN (Listen): invalid IP address",
+		"PORT (Port): invalid uint16",
+		"TIMEOUT (Timeout): invalid duration",
+		"RETRIES (Retries): element 1: invalid int",
+		"MAX_BODY (MaxBody): invalid size",
+		"DB_URL (Database.URL): required variable is not set",
+		"DB_PASSWORD (Database.Password): both DB_PASSWORD and DB_PASSWORD_FILE are set",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected %q in the error, got:\n%s", expected, message)
+		}
+	}
+	if strings.Contains(message, "hunter2") || strings.Contains(message, "parsecs") {
+		t.Errorf("Expected values not to be part of the error, got:\n%s", message)
+	}
+
+	var fieldErr *FieldError
+	if !errors.Is(err, ErrMissingVariable) || !errors.As(err, &fieldErr) {
+		t.Errorf("Expected the error to wrap field errors, got: %v", err)
+	}
+
+	if err := (EnvLoader{}).Load(config); err == nil {
+		t.Error("Expected an error for a target that is not a pointer")
+	}
+}
+
+func TestLoadEnvFileSecret(t *testing.T) {
+	t.Setenv("SYSTEM_TEST_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+	var config struct {
+		Token string `env:"SYSTEM_TEST_TOKEN"`
+	}
+	err := LoadEnv(&config)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the missing secret file to be reported, got: %v", err)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]ByteSize{
+		"0":       0,
+		"512":     512,
+		"512B":    512,
+		"64KB":    64000,
+		"64k":     64000,
+		"64KiB":   65536,
+		"1.5GiB":  1610612736,
+		"2 MB":    2000000,
+		" 1Ti ":   1099511627776,
+		"0.5 kib": 512,
+	}
+	for text, expected := range tests {
+		if size, err := ParseByteSize(text); err != nil || size != expected {
+			t.Errorf("Unexpected size for %q. Expected: %d, Got: %d (%v)", text, expected, size, err)
+		}
+	}
+
+	for _, text := range []string{"", "MB", "-5MB", "5XB", "5 iB", "1.2.3", "10000EB"} {
+		if _, err := ParseByteSize(text); err == nil {
+			t.Errorf("Expected an error for %q", text)
+		}
+	}
+}
+
+func TestParseDotEnv(t *testing.T) {
+	values, err := ParseDotEnv(strings.NewReader(`
+# comment
+PLAIN=value with spaces # comment
+EMPTY=
+export EXPORTED=yes
+SINGLE='literal \n $HOME # not a comment'
+DOUBLE="line\nbreak \"quoted\"" # comment
+URL=https://example.com/#anchor
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse .env: %v", err)
+	}
+	expected := map[string]string{
+		"PLAIN":    "value with spaces",
+		"EMPTY":    "",
+		"EXPORTED": "yes",
+		"SINGLE":   `literal \n $HOME # not a comment`,
+		"DOUBLE":   "line\nbreak \"quoted\"",
+		"URL":      "https://example.com/#anchor",
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Unexpected number of values: %v", values)
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Unexpected value of %s. Expected: %q, Got: %q", key, value, values[key])
+		}
+	}
+
+	for _, content := range []string{"NOVALUE", "=value", "KEY='unterminated", `KEY="unterminated`, `KEY="value" trailing`} {
+		if _, err := ParseDotEnv(strings.NewReader(content)); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Detects whether the process runs in a container or a virtual machine and which resource
+// limits the cgroup imposes, by inspecting /proc, /sys and a few marker files. Only
+// meaningful on Linux, other systems are reported as bare metal without limits.
+//
+// All files are read below Root, so the detector can be pointed at a fixture tree in tests.
+//
+// Fields:
+//   - Root: string - the directory containing proc, sys, run and the marker files, "/" if empty
+//   - Getenv: func(string) string - looks up environment variables, os.Getenv if nil
+//
+// Example usage:
+//
+//	env, _ := EnvironmentDetector{}.Detect()
+//	workers := runtime.NumCPU()
+//	if env.CPULimit > 0 {
+//	  workers = int(math.Ceil(env.CPULimit))
+//	}
+type EnvironmentDetector struct {
+	Root   string
+	Getenv func(string) string
+}
+
+// Describes the environment the process runs in.
+//
+// Fields:
+//   - Container: bool - true if the process runs in a container
+//   - ContainerRuntime: string - e.g. "docker", "podman", "containerd", "cri-o", "lxc" or
+//     "systemd-nspawn", empty if unknown
+//   - ContainerID: string - the id of the container, if it could be determined
+//   - Kubernetes: bool - true if the container is part of a Kubernetes pod
+//   - KubernetesNamespace: string - the namespace of the pod, if the service account is mounted
+//   - CgroupVersion: int - 1 or 2, 0 if cgroups are not available
+//   - CPULimit: float64 - the number of CPUs the cgroup may use, 0 if unlimited
+//   - MemoryLimit: int64 - the memory limit of the cgroup in bytes, 0 if unlimited
+//   - Virtualized: bool - true if the host is a virtual machine
+//   - Hypervisor: string - e.g. "kvm", "qemu", "vmware", "virtualbox", "xen", "hyper-v",
+//     "amazon" or "google", empty if unknown
+//   - SystemVendor: string - the system vendor reported by the firmware
+//   - ProductName: string - the product name reported by the firmware
+type Environment struct {
+	Container           bool    `json:"container" bson:"container" yaml:"container"`
+	ContainerRuntime    string  `json:"container_runtime" bson:"container_runtime" yaml:"container_runtime"`
+	ContainerID         string  `json:"container_id" bson:"container_id" yaml:"container_id"`
+	Kubernetes          bool    `json:"kubernetes" bson:"kubernetes" yaml:"kubernetes"`
+	KubernetesNamespace string  `json:"kubernetes_namespace" bson:"kubernetes_namespace" yaml:"kubernetes_namespace"`
+	CgroupVersion       int     `json:"cgroup_version" bson:"cgroup_version" yaml:"cgroup_version"`
+	CPULimit            float64 `json:"cpu_limit" bson:"cpu_limit" yaml:"cpu_limit"`
+	MemoryLimit         int64   `json:"memory_limit" bson:"memory_limit" yaml:"memory_limit"`
+	Virtualized         bool    `json:"virtualized" bson:"virtualized" yaml:"virtualized"`
+	Hypervisor          string  `json:"hypervisor" bson:"hypervisor" yaml:"hypervisor"`
+	SystemVendor        string  `json:"system_vendor" bson:"system_vendor" yaml:"system_vendor"`
+	ProductName         string  `json:"product_name" bson:"product_name" yaml:"product_name"`
+}
+
+// Markers of container runtimes in cgroup paths, checked in order. A marker matches a
+// whole path component like "/docker/<id>", or the name of a systemd scope or slice like
+// "docker-<id>.scope". Markers ending with a dot match components starting with them.
+var cgroupRuntimes = []struct {
+	marker  string
+	runtime string
+}{
+	{"crio", "cri-o"},
+	{"cri-containerd", "containerd"},
+	{"libpod", "podman"},
+	{"docker", "docker"},
+	{"containerd", "containerd"},
+	{"lxc", "lxc"},
+	{"lxc.payload.", "lxc"},
+	{"kubepods", ""},
+}
+
+// Hypervisors identified by the DMI vendor and product strings, checked in order.
+var dmiHypervisors = []struct {
+	marker     string
+	hypervisor string
+}{
+	{"amazon ec2", "amazon"},
+	{"google compute engine", "google"},
+	{"kvm", "kvm"},
+	{"qemu", "qemu"},
+	{"vmware", "vmware"},
+	{"virtualbox", "virtualbox"},
+	{"innotek", "virtualbox"},
+	{"xen", "xen"},
+	{"microsoft corporation virtual machine", "hyper-v"},
+	{"parallels", "parallels"},
+	{"openstack", "openstack"},
+	{"bochs", "bochs"},
+}
+
+var (
+	containerIDPattern    = regexp.MustCompile(`[0-9a-f]{64}`)
+	containerMountPattern = regexp.MustCompile(`/containers/(?:[^/\s]+/)*?([0-9a-f]{64})/`)
+)
+
+// Detects the environment of this process. Shorthand for EnvironmentDetector{}.Detect().
+func DetectEnvironment() (*Environment, error) {
+	return EnvironmentDetector{}.Detect()
+}
+
+// Detects everything described by Environment. Files that do not exist are treated as
+// absent features, only files that exist but cannot be parsed are reported.
+//
+// Returns:
+//   - *Environment: the detected environment, never nil
+//   - error: the joined errors of the limits that could not be determined
+func (d EnvironmentDetector) Detect() (*Environment, error) {
+	env := &Environment{}
+	var errs []error
+
+	env.Container, env.ContainerRuntime, env.ContainerID = d.ContainerRuntime()
+	env.Kubernetes, env.KubernetesNamespace = d.Kubernetes()
+	if env.Kubernetes {
+		env.Container = true
+	}
+	env.CgroupVersion = d.CgroupVersion()
+
+	var err error
+	if env.CPULimit, err = d.CPULimit(); err != nil {
+		errs = append(errs, err)
+	}
+	if env.MemoryLimit, err = d.MemoryLimit(); err != nil {
+		errs = append(errs, err)
+	}
+	env.Virtualized, env.Hypervisor, env.SystemVendor, env.ProductName = d.Hypervisor()
+
+	return env, errors.Join(errs...)
+}
+
+// Returns whether the process runs in a container, the runtime and the container id.
+// Detected by the marker files /.dockerenv and /run/.containerenv, the "container"
+// environment variable set by systemd-nspawn, LXC and Podman, /run/systemd/container,
+// runtime names in the cgroup paths and the bind mounts of /etc/hostname and
+// /etc/resolv.conf. Services of the host like docker.service are not mistaken for
+// containers.
+func (d EnvironmentDetector) ContainerRuntime() (container bool, runtime string, id string) {
+	cgroups, _ := d.readFile("proc/self/cgroup")
+
+	if match := containerIDPattern.FindString(cgroups); match != "" {
+		id = match
+	} else {
+		// With a private cgroup namespace only the bind mounts of hostname and resolv.conf tell the id
+		id = d.bindMountContainerID()
+	}
+
+	switch {
+	case d.exists(".dockerenv"):
+		return true, "docker", id
+	case d.exists("run/.containerenv"):
+		return true, "podman", id
+	}
+
+	if name := strings.TrimSpace(d.getenv("container")); name != "" {
+		return true, containerRuntimeName(name), id
+	}
+	if name, err := d.readFile("run/systemd/container"); err == nil && strings.TrimSpace(name) != "" {
+		return true, containerRuntimeName(strings.TrimSpace(name)), id
+	}
+
+	for _, line := range strings.Split(cgroups, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		components := strings.Split(parts[2], "/")
+		for _, candidate := range cgroupRuntimes {
+			for _, component := range components {
+				if cgroupComponentMatches(component, candidate.marker) {
+					return true, candidate.runtime, id
+				}
+			}
+		}
+	}
+	if id != "" {
+		return true, "", id
+	}
+	return false, "", ""
+}
+
+// Returns whether the process runs in a Kubernetes pod and its namespace, detected by the
+// service environment variables and the mounted service account.
+func (d EnvironmentDetector) Kubernetes() (bool, string) {
+	for _, dir := range []string{"var/run/secrets/kubernetes.io/serviceaccount", "run/secrets/kubernetes.io/serviceaccount"} {
+		if namespace, err := d.readFile(path.Join(dir, "namespace")); err == nil {
+			return true, strings.TrimSpace(namespace)
+		}
+		if d.exists(path.Join(dir, "token")) {
+			return true, ""
+		}
+	}
+	return d.getenv("KUBERNETES_SERVICE_HOST") != "", ""
+}
+
+// Returns 2 for the unified cgroup hierarchy, 1 for the legacy or hybrid one and 0 if
+// cgroups are not mounted.
+func (d EnvironmentDetector) CgroupVersion() int {
+	if d.exists("sys/fs/cgroup/cgroup.controllers") {
+		return 2
+	}
+	for _, controller := range []string{"memory", "cpu", "cpu,cpuacct", "pids"} {
+		if d.exists(path.Join("sys/fs/cgroup", controller)) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Returns the number of CPUs the cgroup of the process may use, the tightest quota of the
+// cgroup and its ancestors, or 0 if the CPU time is not limited.
+func (d EnvironmentDetector) CPULimit() (float64, error) {
+	limit := 0.0
+	update := func(quota, period float64) {
+		if quota > 0 && period > 0 && (limit == 0 || quota/period < limit) {
+			limit = quota / period
+		}
+	}
+
+	switch d.CgroupVersion() {
+	case 2:
+		for _, dir := range d.cgroupHierarchy("sys/fs/cgroup", "") {
+			content, err := d.readFile(path.Join(dir, "cpu.max"))
+			if err != nil {
+				continue
+			}
+			fields := strings.Fields(content)
+			if len(fields) != 2 {
+				return 0, fmt.Errorf("invalid cpu.max in %s: %q", dir, content)
+			}
+			if fields[0] == "max" {
+				continue
+			}
+			quota, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid cpu.max in %s: %q", dir, content)
+			}
+			period, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid cpu.max in %s: %q", dir, content)
+			}
+			update(quota, period)
+		}
+	case 1:
+		mount := "sys/fs/cgroup/cpu,cpuacct"
+		if !d.exists(mount) {
+			mount = "sys/fs/cgroup/cpu"
+		}
+		for _, dir := range d.cgroupHierarchy(mount, "cpu") {
+			quota, err := d.readInt(path.Join(dir, "cpu.cfs_quota_us"))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			period, err := d.readInt(path.Join(dir, "cpu.cfs_period_us"))
+			if err != nil {
+				return 0, err
+			}
+			update(float64(quota), float64(period))
+		}
+	}
+	return limit, nil
+}
+
+// Returns the memory limit of the cgroup of the process in bytes, the tightest limit of
+// the cgroup and its ancestors, or 0 if the memory is not limited.
+func (d EnvironmentDetector) MemoryLimit() (int64, error) {
+	var limit int64
+	var mount, file, controller string
+	switch d.CgroupVersion() {
+	case 2:
+		mount, file = "sys/fs/cgroup", "memory.max"
+	case 1:
+		mount, file, controller = "sys/fs/cgroup/memory", "memory.limit_in_bytes", "memory"
+	default:
+		return 0, nil
+	}
+
+	for _, dir := range d.cgroupHierarchy(mount, controller) {
+		content, err := d.readFile(path.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		content = strings.TrimSpace(content)
+		if content == "max" {
+			continue
+		}
+		value, err := strconv.ParseInt(content, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s in %s: %q", file, dir, content)
+		}
+		// cgroup v1 reports "unlimited" as the largest page aligned number
+		if value <= 0 || value >= math.MaxInt64/2 {
+			continue
+		}
+		if limit == 0 || value < limit {
+			limit = value
+		}
+	}
+	return limit, nil
+}
+
+// Returns whether the host is a virtual machine, the hypervisor and the system vendor and
+// product name reported by the firmware in /sys/class/dmi/id. The "hypervisor" flag of
+// /proc/cpuinfo and /sys/hypervisor/type are used if the firmware does not tell.
+func (d EnvironmentDetector) Hypervisor() (virtualized bool, hypervisor, vendor, product string) {
+	read := func(name string) string {
+		content, _ := d.readFile(path.Join("sys/class/dmi/id", name))
+		return strings.TrimSpace(content)
+	}
+	vendor = read("sys_vendor")
+	product = read("product_name")
+
+	identification := strings.ToLower(strings.Join([]string{vendor, product, read("bios_vendor"), read("board_vendor")}, " "))
+	for _, candidate := range dmiHypervisors {
+		if strings.Contains(identification, candidate.marker) {
+			return true, candidate.hypervisor, vendor, product
+		}
+	}
+
+	if content, err := d.readFile("sys/hypervisor/type"); err == nil && strings.TrimSpace(content) != "" {
+		return true, strings.TrimSpace(content), vendor, product
+	}
+	if cpuinfo, err := d.readFile("proc/cpuinfo"); err == nil {
+		for _, line := range strings.Split(cpuinfo, "\n") {
+			key, flags, ok := strings.Cut(line, ":")
+			if ok && strings.TrimSpace(key) == "flags" && containsString(strings.Fields(flags), "hypervisor") {
+				return true, "", vendor, product
+			}
+		}
+	}
+	return false, "", vendor, product
+}
+
+// Returns the directories of the cgroup of the process and all its ancestors below the
+// mount point, the innermost first. An empty controller selects the unified hierarchy.
+// If the cgroup path is not visible, e.g. inside a container with a private cgroup
+// namespace, only the mount point itself is returned.
+func (d EnvironmentDetector) cgroupHierarchy(mount, controller string) []string {
+	cgroup := "/"
+	content, _ := d.readFile("proc/self/cgroup")
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if controller == "" && parts[0] == "0" && parts[1] == "" {
+			cgroup = parts[2]
+			break
+		}
+		if controller != "" && containsString(strings.Split(parts[1], ","), controller) {
+			cgroup = parts[2]
+			break
+		}
+	}
+
+	cgroup = path.Clean("/" + cgroup)
+	if !d.exists(path.Join(mount, cgroup)) {
+		cgroup = "/"
+	}
+
+	var dirs []string
+	for {
+		dirs = append(dirs, path.Join(mount, cgroup))
+		if cgroup == "/" {
+			return dirs
+		}
+		cgroup = path.Dir(cgroup)
+	}
+}
+
+// Returns the container id from the bind mounts of /etc/hostname and /etc/resolv.conf,
+// whose source lies in the directory of the container, e.g.
+// "/var/lib/docker/containers/<id>/hostname". Other mounts are ignored, the host of a
+// container runtime sees the directories of all its containers in its own mount table.
+func (d EnvironmentDetector) bindMountContainerID() string {
+	content, err := d.readFile("proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	mounts, _ := ParseMountInfo(strings.NewReader(content))
+	for _, mount := range mounts {
+		if mount.MountPoint != "/etc/hostname" && mount.MountPoint != "/etc/resolv.conf" {
+			continue
+		}
+		if match := containerMountPattern.FindStringSubmatch(mount.Root); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// Returns true if a component of a cgroup path belongs to the container runtime marker,
+// i.e. equals it or is a systemd scope or slice named after it.
+func cgroupComponentMatches(component, marker string) bool {
+	if strings.HasSuffix(marker, ".") {
+		return strings.HasPrefix(component, marker)
+	}
+	if component == marker {
+		return true
+	}
+	name := strings.TrimSuffix(strings.TrimSuffix(component, ".scope"), ".slice")
+	if name == component {
+		return false
+	}
+	return name == marker || strings.HasPrefix(name, marker+"-")
+}
+
+// Maps the value of the "container" variable to the runtime name used by Environment.
+func containerRuntimeName(name string) string {
+	switch name {
+	case "oci":
+		return ""
+	case "lxc-libvirt":
+		return "lxc"
+	}
+	return name
+}
+
+func (d EnvironmentDetector) getenv(key string) string {
+	if d.Getenv != nil {
+		return d.Getenv(key)
+	}
+	return os.Getenv(key)
+}
+
+func (d EnvironmentDetector) exists(name string) bool {
+	_, err := os.Stat(rootPath(d.Root, name))
+	return err == nil
+}
+
+func (d EnvironmentDetector) readFile(name string) (string, error) {
+	content, err := os.ReadFile(rootPath(d.Root, name))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (d EnvironmentDetector) readInt(name string) (int64, error) {
+	content, err := d.readFile(name)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(content), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value in %s: %q", name, content)
+	}
+	return value, nil
+}
@@ -0,0 +1,166 @@
+package system
+
+import (
+	"testing"
+)
+
+func noEnv(string) string { return "" }
+
+func TestEnvironmentDetectorKubernetes(t *testing.T) {
+	root := t.TempDir()
+	id := "3f4e5d6c7b8a99887766554433221100ffeeddccbbaa99887766554433221100"
+	writeFixture(t, root, map[string]string{
+		"proc/self/cgroup":                                              "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + id + ".scope\n",
+		"sys/fs/cgroup/cgroup.controllers":                              "cpuset cpu io memory pids\n",
+		"sys/fs/cgroup/kubepods.slice/cpu.max":                          "max 100000\n",
+		"sys/fs/cgroup/kubepods.slice/memory.max":                       "8589934592\n",
+		"sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice/cpu.max": "400000 100000\n",
+		"sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + id + ".scope/cpu.max":    "150000 100000\n",
+		"sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + id + ".scope/memory.max": "max\n",
+		"var/run/secrets/kubernetes.io/serviceaccount/namespace":                                                                            "payments",
+		"sys/class/dmi/id/sys_vendor":   "Amazon EC2\n",
+		"sys/class/dmi/id/product_name": "m5.xlarge\n",
+	})
+
+	env, err := EnvironmentDetector{Root: root, Getenv: noEnv}.Detect()
+	if err != nil {
+		t.Fatalf("Failed to detect environment: %v", err)
+	}
+	expected := Environment{
+		Container: true, ContainerRuntime: "containerd", ContainerID: id,
+		Kubernetes: true, KubernetesNamespace: "payments",
+		CgroupVersion: 2, CPULimit: 1.5, MemoryLimit: 8589934592,
+		Virtualized: true, Hypervisor: "amazon", SystemVendor: "Amazon EC2", ProductName: "m5.xlarge",
+	}
+	if *env != expected {
+		t.Errorf("Unexpected environment.\nExpected: %+v\nGot:      %+v", expected, *env)
+	}
+}
+
+func TestEnvironmentDetectorDockerCgroupV1(t *testing.T) {
+	root := t.TempDir()
+	id := "aa4e5d6c7b8a99887766554433221100ffeeddccbbaa99887766554433221100"
+	writeFixture(t, root, map[string]string{
+		".dockerenv": "",
+		"proc/self/cgroup": "12:memory:/docker/" + id + "\n" +
+			"4:cpu,cpuacct:/docker/" + id + "\n" +
+			"1:name=systemd:/docker/" + id + "\n",
+		// The container only sees its own cgroup at the mount point
+		"sys/fs/cgroup/memory/memory.limit_in_bytes":  "536870912\n",
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  "50000\n",
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+		"proc/cpuinfo":                  "processor\t: 0\nflags\t\t: fpu vme de pse hypervisor lahf_lm\n",
+		"sys/class/dmi/id/sys_vendor":   "QEMU\n",
+		"sys/class/dmi/id/product_name": "Standard PC (Q35 + ICH9, 2009)\n",
+	})
+
+	env, err := EnvironmentDetector{Root: root, Getenv: noEnv}.Detect()
+	if err != nil {
+		t.Fatalf("Failed to detect environment: %v", err)
+	}
+	expected := Environment{
+		Container: true, ContainerRuntime: "docker", ContainerID: id,
+		CgroupVersion: 1, CPULimit: 0.5, MemoryLimit: 536870912,
+		Virtualized: true, Hypervisor: "qemu", SystemVendor: "QEMU", ProductName: "Standard PC (Q35 + ICH9, 2009)",
+	}
+	if *env != expected {
+		t.Errorf("Unexpected environment.\nExpected: %+v\nGot:      %+v", expected, *env)
+	}
+}
+
+func TestEnvironmentDetectorBareMetal(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"proc/self/cgroup":                    "0::/user.slice/user-1000.slice/session-2.scope\n",
+		"sys/fs/cgroup/cgroup.controllers":    "cpu memory\n",
+		"sys/fs/cgroup/user.slice/memory.max": "max\n",
+		"proc/cpuinfo":                        "processor\t: 0\nflags\t\t: fpu vme de pse\n",
+		"sys/class/dmi/id/sys_vendor":         "Dell Inc.\n",
+		"sys/class/dmi/id/product_name":       "PowerEdge R740\n",
+	})
+
+	env, err := EnvironmentDetector{Root: root, Getenv: noEnv}.Detect()
+	if err != nil {
+		t.Fatalf("Failed to detect environment: %v", err)
+	}
+	expected := Environment{CgroupVersion: 2, SystemVendor: "Dell Inc.", ProductName: "PowerEdge R740"}
+	if *env != expected {
+		t.Errorf("Unexpected environment.\nExpected: %+v\nGot:      %+v", expected, *env)
+	}
+
+	// The variables set by systemd-nspawn and the Kubernetes service links are honored
+	getenv := func(key string) string {
+		return map[string]string{"container": "systemd-nspawn", "KUBERNETES_SERVICE_HOST": "10.0.0.1"}[key]
+	}
+	env, _ = EnvironmentDetector{Root: root, Getenv: getenv}.Detect()
+	if !env.Container || env.ContainerRuntime != "systemd-nspawn" || !env.Kubernetes {
+		t.Errorf("Expected the environment variables to be detected, got: %+v", *env)
+	}
+}
+
+func TestEnvironmentDetectorDockerHost(t *testing.T) {
+	root := t.TempDir()
+	id := "bb4e5d6c7b8a99887766554433221100ffeeddccbbaa99887766554433221100"
+	writeFixture(t, root, map[string]string{
+		"proc/self/cgroup": "0::/system.slice/docker.service\n",
+		"proc/self/mountinfo": "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n" +
+			"80 22 0:60 / /var/lib/docker/containers/" + id + "/mounts/shm rw,nosuid,nodev,noexec,relatime shared:40 - tmpfs shm rw,size=65536k\n" +
+			"81 22 0:61 / /var/lib/lxcfs rw,nosuid,nodev,relatime shared:41 - fuse.lxcfs lxcfs rw\n",
+		"sys/fs/cgroup/cgroup.controllers": "cpu memory\n",
+	})
+
+	env, err := EnvironmentDetector{Root: root, Getenv: noEnv}.Detect()
+	if err != nil {
+		t.Fatalf("Failed to detect environment: %v", err)
+	}
+	if env.Container || env.ContainerRuntime != "" || env.ContainerID != "" {
+		t.Errorf("Expected the host of a container runtime not to be a container, got: %+v", *env)
+	}
+
+	for _, cgroup := range []string{"0::/system.slice/lxcfs.service\n", "0::/system.slice/containerd.service\n"} {
+		writeFixture(t, root, map[string]string{"proc/self/cgroup": cgroup})
+		if container, runtime, _ := (EnvironmentDetector{Root: root, Getenv: noEnv}).ContainerRuntime(); container {
+			t.Errorf("Expected %q not to be a container, got runtime %q", cgroup, runtime)
+		}
+	}
+}
+
+func TestEnvironmentDetectorPrivateCgroupNamespace(t *testing.T) {
+	root := t.TempDir()
+	id := "cc4e5d6c7b8a99887766554433221100ffeeddccbbaa99887766554433221100"
+	writeFixture(t, root, map[string]string{
+		"proc/self/cgroup": "0::/\n",
+		"proc/self/mountinfo": "600 500 0:70 / / rw,relatime - overlay overlay rw\n" +
+			"610 600 8:1 /var/lib/docker/containers/" + id + "/resolv.conf /etc/resolv.conf rw,relatime - ext4 /dev/sda1 rw\n" +
+			"611 600 8:1 /var/lib/docker/containers/" + id + "/hostname /etc/hostname rw,relatime - ext4 /dev/sda1 rw\n",
+	})
+
+	container, runtime, containerID := EnvironmentDetector{Root: root, Getenv: noEnv}.ContainerRuntime()
+	if !container || runtime != "" || containerID != id {
+		t.Errorf("Expected the id from the bind mounts, got %v, %q, %q", container, runtime, containerID)
+	}
+
+	// Scopes named after the runtime identify it
+	writeFixture(t, root, map[string]string{"proc/self/cgroup": "0::/system.slice/docker-" + id + ".scope\n"})
+	if container, runtime, _ := (EnvironmentDetector{Root: root, Getenv: noEnv}).ContainerRuntime(); !container || runtime != "docker" {
+		t.Errorf("Expected a docker scope to be detected, got %v, %q", container, runtime)
+	}
+}
+
+func TestEnvironmentDetectorInvalidLimits(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"proc/self/cgroup":                 "0::/\n",
+		"sys/fs/cgroup/cgroup.controllers": "cpu memory\n",
+		"sys/fs/cgroup/cpu.max":            "lots\n",
+		"sys/fs/cgroup/memory.max":         "1G\n",
+	})
+
+	env, err := EnvironmentDetector{Root: root, Getenv: noEnv}.Detect()
+	if err == nil {
+		t.Error("Expected errors for malformed limits")
+	}
+	if env == nil || env.CgroupVersion != 2 {
+		t.Errorf("Expected the rest of the environment to be detected, got: %+v", env)
+	}
+}
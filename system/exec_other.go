@@ -0,0 +1,15 @@
+//go:build !unix
+
+package system
+
+import (
+	"os/exec"
+)
+
+// Process groups are not available on this platform, context cancellation only kills
+// the started process itself.
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Kill()
+	}
+}
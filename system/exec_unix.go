@@ -0,0 +1,21 @@
+//go:build unix
+
+package system
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Places the command in its own process group and makes context cancellation kill the
+// whole group, so grandchildren spawned by a shell do not outlive the command.
+func configureProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,88 @@
+package system
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// Runs commands on behalf of the system package.
+//
+// The default implementation, OSExecutor, spawns real processes. Tests can replace it
+// with a FakeExecutor, either globally using SetDefaultExecutor or for a single command
+// using Command.WithExecutor.
+//
+// Implementations return a nil error only if the command exited successfully. If the
+// command was started but failed, both the result and the error are returned. If it could
+// not be started at all, the result is nil.
+//
+// LookPath resolves a program name to an absolute path the way the executor would find it,
+// returning an error wrapping exec.ErrNotFound if there is no such program.
+type Executor interface {
+	Execute(ctx context.Context, cmd *Command) (*CommandResult, error)
+	LookPath(file string) (string, error)
+}
+
+// Executes commands as processes of the operating system.
+type OSExecutor struct{}
+
+// Starts the command as a new process and waits for it to finish.
+func (OSExecutor) Execute(ctx context.Context, cmd *Command) (*CommandResult, error) {
+	return execute(ctx, cmd)
+}
+
+// Searches for the program in the directories named by the PATH environment variable
+// and returns its absolute path.
+func (OSExecutor) LookPath(file string) (string, error) {
+	path, err := exec.LookPath(file)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+var (
+	executorMu      sync.RWMutex
+	defaultExecutor Executor = OSExecutor{}
+)
+
+// Returns the Executor used by RunCommand, CommandExists and every Command
+// that has no executor of its own.
+func DefaultExecutor() Executor {
+	executorMu.RLock()
+	defer executorMu.RUnlock()
+	return defaultExecutor
+}
+
+// Replaces the Executor used by the package level functions and returns a function
+// restoring the previous one. Passing nil restores the OSExecutor.
+//
+// Parameters:
+//   - executor: Executor - the executor to use from now on
+//
+// Returns:
+//   - func(): restores the previously configured executor
+//
+// Example usage:
+//
+//	fake := NewFakeExecutor()
+//	fake.On(`^git pull`).Stdout("Already up to date.\n")
+//	restore := SetDefaultExecutor(fake)
+//	defer restore()
+func SetDefaultExecutor(executor Executor) func() {
+	if executor == nil {
+		executor = OSExecutor{}
+	}
+
+	executorMu.Lock()
+	previous := defaultExecutor
+	defaultExecutor = executor
+	executorMu.Unlock()
+
+	return func() {
+		executorMu.Lock()
+		defaultExecutor = previous
+		executorMu.Unlock()
+	}
+}
@@ -0,0 +1,257 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Returned (wrapped) by a FakeExecutor when no rule matches the executed command.
+var ErrUnexpectedCommand = errors.New("unexpected command")
+
+// An Executor for tests that never spawns processes. Commands are matched against
+// scripted rules by regular expressions on their string representation, and every
+// execution is recorded for later inspection.
+//
+// Example usage:
+//
+//	fake := NewFakeExecutor()
+//	fake.On(`^git fetch`).Times(1).ExitCode(128).Stderr("network unreachable")
+//	fake.On(`^git fetch`).Stdout("ok\n")
+//	restore := SetDefaultExecutor(fake)
+//	defer restore()
+//
+//	// ... exercise the code under test ...
+//
+//	if len(fake.Calls()) != 2 {
+//	  t.Error("expected a retry")
+//	}
+type FakeExecutor struct {
+	mu    sync.Mutex
+	rules []*FakeRule
+	calls []FakeCall
+	paths map[string]string
+}
+
+// Describes a command executed by a FakeExecutor.
+//
+// Fields:
+//   - Command: *Command - the executed command
+//   - Line: string - the string representation the rules were matched against
+//   - Stdin: []byte - everything that was fed to the command's standard input
+//   - Time: time.Time - the moment the command was executed
+type FakeCall struct {
+	Command *Command
+	Line    string
+	Stdin   []byte
+	Time    time.Time
+}
+
+// A scripted response of a FakeExecutor. The setters return the rule for chaining.
+type FakeRule struct {
+	pattern  *regexp.Regexp
+	stdout   []byte
+	stderr   []byte
+	exitCode int
+	err      error
+	delay    time.Duration
+	respond  func(cmd *Command) (*CommandResult, error)
+	times    int
+	used     int
+}
+
+// Creates an empty FakeExecutor. Commands fail with ErrUnexpectedCommand until rules are added.
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{}
+}
+
+// Adds a rule for commands whose string representation matches the regular expression.
+// Rules are evaluated in the order they were added, the first matching rule with
+// remaining uses wins. Panics if the pattern does not compile.
+//
+// Parameters:
+//   - pattern: string - the regular expression the command line has to match
+//
+// Returns:
+//   - *FakeRule: the new rule, succeeding with empty output until configured otherwise
+func (f *FakeExecutor) On(pattern string) *FakeRule {
+	rule := &FakeRule{pattern: regexp.MustCompile(pattern)}
+
+	f.mu.Lock()
+	f.rules = append(f.rules, rule)
+	f.mu.Unlock()
+
+	return rule
+}
+
+// Returns the commands executed so far, in order.
+func (f *FakeExecutor) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeCall(nil), f.calls...)
+}
+
+// Makes LookPath resolve the program name to the given path.
+func (f *FakeExecutor) AddPath(name, path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.paths == nil {
+		f.paths = make(map[string]string)
+	}
+	f.paths[name] = path
+}
+
+// Removes all rules, paths and recorded calls.
+func (f *FakeExecutor) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = nil
+	f.calls = nil
+	f.paths = nil
+}
+
+// Resolves the program name using the paths added with AddPath.
+// Unknown programs are reported like exec.LookPath does, with an error wrapping exec.ErrNotFound.
+func (f *FakeExecutor) LookPath(file string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if path, ok := f.paths[file]; ok {
+		return path, nil
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
+
+// Records the command and answers it with the first matching rule.
+func (f *FakeExecutor) Execute(ctx context.Context, cmd *Command) (*CommandResult, error) {
+	call := FakeCall{Command: cmd, Line: cmd.String(), Time: time.Now()}
+	if cmd.stdin != nil {
+		call.Stdin, _ = io.ReadAll(cmd.stdin)
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	rule := f.match(call.Line)
+	f.mu.Unlock()
+
+	if rule == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedCommand, call.Line)
+	}
+	return rule.apply(ctx, cmd, call.Time)
+}
+
+// Finds the first rule matching the command line and consumes one of its uses.
+// Must be called with the mutex held.
+func (f *FakeExecutor) match(line string) *FakeRule {
+	for _, rule := range f.rules {
+		if rule.times > 0 && rule.used >= rule.times {
+			continue
+		}
+		if rule.pattern.MatchString(line) {
+			rule.used++
+			return rule
+		}
+	}
+	return nil
+}
+
+// Sets the standard output returned by the rule.
+func (r *FakeRule) Stdout(stdout string) *FakeRule {
+	r.stdout = []byte(stdout)
+	return r
+}
+
+// Sets the standard error returned by the rule.
+func (r *FakeRule) Stderr(stderr string) *FakeRule {
+	r.stderr = []byte(stderr)
+	return r
+}
+
+// Sets the exit code returned by the rule. A non-zero code makes the execution fail.
+func (r *FakeRule) ExitCode(code int) *FakeRule {
+	r.exitCode = code
+	return r
+}
+
+// Makes the rule fail as if the command could not be started.
+func (r *FakeRule) Err(err error) *FakeRule {
+	r.err = err
+	return r
+}
+
+// Makes the rule take the given time, honoring context cancellation and timeouts.
+func (r *FakeRule) Delay(delay time.Duration) *FakeRule {
+	r.delay = delay
+	return r
+}
+
+// Limits how often the rule matches. Zero, the default, means unlimited.
+func (r *FakeRule) Times(times int) *FakeRule {
+	r.times = times
+	return r
+}
+
+// Computes the response dynamically, replacing any canned output of the rule.
+func (r *FakeRule) Respond(respond func(cmd *Command) (*CommandResult, error)) *FakeRule {
+	r.respond = respond
+	return r
+}
+
+// Builds the canned result of the rule, delivering its output to the command's line handler.
+func (r *FakeRule) apply(ctx context.Context, cmd *Command, start time.Time) (*CommandResult, error) {
+	if r.respond != nil {
+		return r.respond(cmd)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	result := &CommandResult{
+		Command:   cmd.String(),
+		Stdout:    append([]byte(nil), r.stdout...),
+		Stderr:    append([]byte(nil), r.stderr...),
+		ExitCode:  r.exitCode,
+		StartTime: start,
+
+		StdoutSize: int64(len(r.stdout)),
+		StderrSize: int64(len(r.stderr)),
+	}
+
+	if r.delay > 0 {
+		timer := time.NewTimer(r.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			result.ExitCode = -1
+			result.Signal = "killed"
+			result.EndTime = time.Now()
+			result.Duration = result.EndTime.Sub(start)
+			return result, contextError(ctx, result, ctx.Err())
+		}
+	}
+
+	if cmd.lineHandler != nil {
+		var mu sync.Mutex
+		stdout := newLineWriter(StreamStdout, cmd.lineHandler, cmd.maxLineLength, &mu)
+		stdout.Write(result.Stdout)
+		stdout.Flush()
+		stderr := newLineWriter(StreamStderr, cmd.lineHandler, cmd.maxLineLength, &mu)
+		stderr.Write(result.Stderr)
+		stderr.Flush()
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(start)
+
+	if result.ExitCode != 0 {
+		return result, fmt.Errorf("exit status %d", result.ExitCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeExecutorRules(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On(`^git fetch`).Times(1).ExitCode(128).Stderr("network unreachable\n")
+	fake.On(`^git fetch`).Stdout("fetched\n")
+
+	ctx := context.Background()
+	result, err := NewCommand("git", "fetch", "origin").WithExecutor(fake).Run(ctx)
+	if err == nil || result == nil || result.ExitCode != 128 {
+		t.Fatalf("Expected the first rule to fail with exit code 128, got %+v, %v", result, err)
+	}
+	if !strings.Contains(err.Error(), "git fetch origin") {
+		t.Errorf("Error should name the command, got: %v", err)
+	}
+
+	result, err = NewCommand("git", "fetch", "origin").WithExecutor(fake).Run(ctx)
+	if err != nil {
+		t.Fatalf("Expected the second rule to succeed, got: %v", err)
+	}
+	if result.StdoutString() != "fetched\n" {
+		t.Errorf("Unexpected stdout: %q", result.Stdout)
+	}
+
+	_, err = NewCommand("rm", "-rf", "/").WithExecutor(fake).Run(ctx)
+	if !errors.Is(err, ErrUnexpectedCommand) {
+		t.Errorf("Expected ErrUnexpectedCommand, got: %v", err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("Expected 3 recorded calls, got %d", len(calls))
+	}
+	if calls[0].Line != "git fetch origin" || calls[2].Command.Name() != "rm" {
+		t.Errorf("Unexpected recorded calls: %+v", calls)
+	}
+}
+
+func TestFakeExecutorAsDefault(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On(`^uptime$`).Stdout("up 3 days\n")
+	fake.On(`^cat$`).Respond(func(cmd *Command) (*CommandResult, error) {
+		return &CommandResult{Command: cmd.String(), Stdout: []byte(strings.Join(cmd.Args(), ","))}, nil
+	})
+
+	restore := SetDefaultExecutor(fake)
+	defer restore()
+
+	output, err := RunCommand("uptime")
+	if err != nil {
+		t.Fatalf("Failed to run faked command: %v", err)
+	}
+	if output != "up 3 days\n" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	_, err = NewCommand("cat").WithStdinString("input").Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run faked command: %v", err)
+	}
+	if calls := fake.Calls(); string(calls[len(calls)-1].Stdin) != "input" {
+		t.Errorf("Stdin was not recorded, got %q", calls[len(calls)-1].Stdin)
+	}
+
+	restore()
+	if _, ok := DefaultExecutor().(OSExecutor); !ok {
+		t.Errorf("Restore did not reinstate the OS executor, got %T", DefaultExecutor())
+	}
+}
+
+func TestFakeExecutorStreamsAndTimesOut(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On(`^build$`).Stdout("step 1\nstep 2\n")
+	fake.On(`^deploy$`).Delay(time.Minute)
+
+	var lines []string
+	_, err := NewCommand("build").
+		WithExecutor(fake).
+		WithLineHandler(func(line OutputLine) { lines = append(lines, line.Text) }).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run faked command: %v", err)
+	}
+	if strings.Join(lines, "|") != "step 1|step 2" {
+		t.Errorf("Unexpected streamed lines: %v", lines)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = NewCommand("deploy").WithExecutor(fake).Run(ctx)
+	if !errors.Is(err, ErrCommandTimeout) {
+		t.Errorf("Expected ErrCommandTimeout, got: %v", err)
+	}
+}
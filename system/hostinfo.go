@@ -0,0 +1,510 @@
+package system
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reads static information about the host directly from /proc, /sys and /etc, without
+// running any commands. Only meaningful on Linux.
+//
+// All files are read below Root, so the collector can be pointed at a copy of these
+// directories, e.g. a fixture tree in tests or the host filesystem mounted into a container.
+//
+// Fields:
+//   - Root: string - the directory containing proc, sys and etc, "/" if empty
+//
+// Example usage:
+//
+//	collector := HostInfoCollector{Root: "/host"}
+//	memory, err := collector.Memory()
+//	if err != nil {
+//	  return err
+//	}
+//	fmt.Printf("%d of %d bytes available\n", memory.Available, memory.Total)
+type HostInfoCollector struct {
+	Root string
+}
+
+// Holds everything HostInfoCollector.Collect gathers about the host.
+//
+// Fields:
+//   - Hostname: string - the name of the host
+//   - Kernel: KernelInfo - the running kernel
+//   - OS: OSRelease - the distribution, from /etc/os-release
+//   - CPU: CPUInfo - the processors
+//   - Memory: MemoryInfo - the memory and swap usage
+//   - Load: LoadAverage - the load averages
+//   - Uptime: time.Duration - the time since the host booted
+//   - BootTime: time.Time - the moment the host booted
+type HostInfo struct {
+	Hostname string        `json:"hostname" bson:"hostname" yaml:"hostname"`
+	Kernel   KernelInfo    `json:"kernel" bson:"kernel" yaml:"kernel"`
+	OS       OSRelease     `json:"os" bson:"os" yaml:"os"`
+	CPU      CPUInfo       `json:"cpu" bson:"cpu" yaml:"cpu"`
+	Memory   MemoryInfo    `json:"memory" bson:"memory" yaml:"memory"`
+	Load     LoadAverage   `json:"load" bson:"load" yaml:"load"`
+	Uptime   time.Duration `json:"uptime" bson:"uptime" yaml:"uptime"`
+	BootTime time.Time     `json:"boot_time" bson:"boot_time" yaml:"boot_time"`
+}
+
+// Describes the running kernel.
+//
+// Fields:
+//   - Name: string - the kernel name, e.g. "Linux"
+//   - Release: string - the kernel release, e.g. "6.1.0-13-amd64"
+//   - Version: string - the build version, e.g. "#1 SMP PREEMPT_DYNAMIC Debian 6.1.55-1"
+type KernelInfo struct {
+	Name    string `json:"name" bson:"name" yaml:"name"`
+	Release string `json:"release" bson:"release" yaml:"release"`
+	Version string `json:"version" bson:"version" yaml:"version"`
+}
+
+// Holds the identification of the operating system from the os-release file.
+//
+// Fields:
+//   - ID: string - the lower-case distribution id, e.g. "debian"
+//   - IDLike: []string - the ids of related distributions, e.g. ["rhel", "fedora"]
+//   - Name: string - the distribution name, e.g. "Debian GNU/Linux"
+//   - PrettyName: string - the name for display, including the version
+//   - Version: string - the version, possibly including a code name
+//   - VersionID: string - the machine readable version, e.g. "12"
+//   - VersionCodename: string - the code name of the release, e.g. "bookworm"
+//   - Fields: map[string]string - every field of the file, including the ones above
+type OSRelease struct {
+	ID              string            `json:"id" bson:"id" yaml:"id"`
+	IDLike          []string          `json:"id_like" bson:"id_like" yaml:"id_like"`
+	Name            string            `json:"name" bson:"name" yaml:"name"`
+	PrettyName      string            `json:"pretty_name" bson:"pretty_name" yaml:"pretty_name"`
+	Version         string            `json:"version" bson:"version" yaml:"version"`
+	VersionID       string            `json:"version_id" bson:"version_id" yaml:"version_id"`
+	VersionCodename string            `json:"version_codename" bson:"version_codename" yaml:"version_codename"`
+	Fields          map[string]string `json:"fields" bson:"fields" yaml:"fields"`
+}
+
+// Describes the processors of the host.
+//
+// Fields:
+//   - ModelName: string - the model of the first processor
+//   - Vendor: string - the vendor id, e.g. "GenuineIntel"
+//   - Sockets: int - the number of physical packages
+//   - PhysicalCores: int - the number of cores, without hyper-threading siblings
+//   - LogicalCores: int - the number of logical processors listed in /proc/cpuinfo
+//   - OnlineCores: int - the number of logical processors currently online
+//   - MHz: float64 - the current clock speed of the first processor, 0 if unknown
+type CPUInfo struct {
+	ModelName     string  `json:"model_name" bson:"model_name" yaml:"model_name"`
+	Vendor        string  `json:"vendor" bson:"vendor" yaml:"vendor"`
+	Sockets       int     `json:"sockets" bson:"sockets" yaml:"sockets"`
+	PhysicalCores int     `json:"physical_cores" bson:"physical_cores" yaml:"physical_cores"`
+	LogicalCores  int     `json:"logical_cores" bson:"logical_cores" yaml:"logical_cores"`
+	OnlineCores   int     `json:"online_cores" bson:"online_cores" yaml:"online_cores"`
+	MHz           float64 `json:"mhz" bson:"mhz" yaml:"mhz"`
+}
+
+// Describes the memory and swap of the host. All values are in bytes.
+//
+// Fields:
+//   - Total: int64 - the usable physical memory
+//   - Free: int64 - the memory not used at all
+//   - Available: int64 - the memory available for new allocations without swapping
+//   - Used: int64 - Total minus Available
+//   - Buffers: int64 - the memory used for block device buffers
+//   - Cached: int64 - the memory used for the page cache
+//   - SwapTotal: int64 - the size of all swap areas
+//   - SwapFree: int64 - the unused swap space
+//   - SwapUsed: int64 - SwapTotal minus SwapFree
+type MemoryInfo struct {
+	Total     int64 `json:"total" bson:"total" yaml:"total"`
+	Free      int64 `json:"free" bson:"free" yaml:"free"`
+	Available int64 `json:"available" bson:"available" yaml:"available"`
+	Used      int64 `json:"used" bson:"used" yaml:"used"`
+	Buffers   int64 `json:"buffers" bson:"buffers" yaml:"buffers"`
+	Cached    int64 `json:"cached" bson:"cached" yaml:"cached"`
+	SwapTotal int64 `json:"swap_total" bson:"swap_total" yaml:"swap_total"`
+	SwapFree  int64 `json:"swap_free" bson:"swap_free" yaml:"swap_free"`
+	SwapUsed  int64 `json:"swap_used" bson:"swap_used" yaml:"swap_used"`
+}
+
+// Holds the system load averages and the number of scheduling entities.
+//
+// Fields:
+//   - Load1: float64 - the load average over the last minute
+//   - Load5: float64 - the load average over the last 5 minutes
+//   - Load15: float64 - the load average over the last 15 minutes
+//   - Running: int - the number of currently runnable threads
+//   - Total: int - the number of threads on the system
+type LoadAverage struct {
+	Load1   float64 `json:"load1" bson:"load1" yaml:"load1"`
+	Load5   float64 `json:"load5" bson:"load5" yaml:"load5"`
+	Load15  float64 `json:"load15" bson:"load15" yaml:"load15"`
+	Running int     `json:"running" bson:"running" yaml:"running"`
+	Total   int     `json:"total" bson:"total" yaml:"total"`
+}
+
+// Collects information about the host this process runs on.
+// Shorthand for HostInfoCollector{}.Collect().
+func CollectHostInfo() (*HostInfo, error) {
+	return HostInfoCollector{}.Collect()
+}
+
+// Collects all information about the host. Parts that cannot be read are left empty,
+// the returned error describes all of them.
+//
+// Returns:
+//   - *HostInfo: the information about the host, never nil
+//   - error: the joined errors of all parts that could not be read
+func (h HostInfoCollector) Collect() (*HostInfo, error) {
+	info := &HostInfo{}
+	var errs []error
+
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var err error
+	info.Hostname, err = h.Hostname()
+	collect(err)
+	info.Kernel, err = h.Kernel()
+	collect(err)
+	info.OS, err = h.OSRelease()
+	collect(err)
+	info.CPU, err = h.CPU()
+	collect(err)
+	info.Memory, err = h.Memory()
+	collect(err)
+	info.Load, err = h.Load()
+	collect(err)
+	info.Uptime, err = h.Uptime()
+	collect(err)
+	info.BootTime, err = h.BootTime()
+	collect(err)
+
+	return info, errors.Join(errs...)
+}
+
+// Returns the host name from /proc/sys/kernel/hostname, or /etc/hostname if that is missing.
+func (h HostInfoCollector) Hostname() (string, error) {
+	content, err := h.readFile("proc/sys/kernel/hostname")
+	if err != nil {
+		var fallbackErr error
+		content, fallbackErr = h.readFile("etc/hostname")
+		if fallbackErr != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(content), nil
+}
+
+// Returns the name, release and version of the running kernel from /proc/sys/kernel.
+func (h HostInfoCollector) Kernel() (KernelInfo, error) {
+	var kernel KernelInfo
+	for _, field := range []struct {
+		file  string
+		value *string
+	}{
+		{"ostype", &kernel.Name},
+		{"osrelease", &kernel.Release},
+		{"version", &kernel.Version},
+	} {
+		content, err := h.readFile("proc/sys/kernel/" + field.file)
+		if err != nil {
+			return kernel, err
+		}
+		*field.value = strings.TrimSpace(content)
+	}
+	return kernel, nil
+}
+
+// Returns the fields of /etc/os-release, or /usr/lib/os-release if that is missing.
+// Values are unquoted and unescaped like a shell would do.
+func (h HostInfoCollector) OSRelease() (OSRelease, error) {
+	content, err := h.readFile("etc/os-release")
+	if err != nil {
+		var fallbackErr error
+		content, fallbackErr = h.readFile("usr/lib/os-release")
+		if fallbackErr != nil {
+			return OSRelease{}, err
+		}
+	}
+
+	release := OSRelease{Fields: make(map[string]string)}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		release.Fields[key] = unquoteOSReleaseValue(value)
+	}
+
+	release.ID = release.Fields["ID"]
+	release.IDLike = strings.Fields(release.Fields["ID_LIKE"])
+	release.Name = release.Fields["NAME"]
+	release.PrettyName = release.Fields["PRETTY_NAME"]
+	release.Version = release.Fields["VERSION"]
+	release.VersionID = release.Fields["VERSION_ID"]
+	release.VersionCodename = release.Fields["VERSION_CODENAME"]
+	return release, nil
+}
+
+// Returns the processor model and the number of sockets and cores from /proc/cpuinfo,
+// and the number of online processors from /sys/devices/system/cpu/online.
+func (h HostInfoCollector) CPU() (CPUInfo, error) {
+	content, err := h.readFile("proc/cpuinfo")
+	if err != nil {
+		return CPUInfo{}, err
+	}
+
+	var cpu CPUInfo
+	sockets := make(map[string]bool)
+	cores := make(map[string]bool)
+	physicalID := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "processor":
+			cpu.LogicalCores++
+		case "model name", "Processor", "cpu model":
+			if cpu.ModelName == "" {
+				cpu.ModelName = value
+			}
+		case "vendor_id":
+			if cpu.Vendor == "" {
+				cpu.Vendor = value
+			}
+		case "cpu MHz":
+			if cpu.MHz == 0 {
+				cpu.MHz, _ = strconv.ParseFloat(value, 64)
+			}
+		case "physical id":
+			physicalID = value
+			sockets[value] = true
+		case "core id":
+			cores[physicalID+"/"+value] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return cpu, fmt.Errorf("failed to read cpuinfo: %w", err)
+	}
+
+	// Some architectures do not report the topology, every processor counts as a core then
+	cpu.Sockets = len(sockets)
+	if cpu.Sockets == 0 && cpu.LogicalCores > 0 {
+		cpu.Sockets = 1
+	}
+	cpu.PhysicalCores = len(cores)
+	if cpu.PhysicalCores == 0 {
+		cpu.PhysicalCores = cpu.LogicalCores
+	}
+
+	cpu.OnlineCores = cpu.LogicalCores
+	if online, err := h.readFile("sys/devices/system/cpu/online"); err == nil {
+		if count, err := countCPUList(strings.TrimSpace(online)); err == nil {
+			cpu.OnlineCores = count
+		}
+	}
+
+	return cpu, nil
+}
+
+// Returns the memory and swap usage from /proc/meminfo.
+func (h HostInfoCollector) Memory() (MemoryInfo, error) {
+	content, err := h.readFile("proc/meminfo")
+	if err != nil {
+		return MemoryInfo{}, err
+	}
+
+	values := make(map[string]int64)
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			n *= 1024
+		}
+		values[key] = n
+	}
+
+	if _, ok := values["MemTotal"]; !ok {
+		return MemoryInfo{}, fmt.Errorf("no MemTotal in %s", h.path("proc/meminfo"))
+	}
+
+	memory := MemoryInfo{
+		Total:     values["MemTotal"],
+		Free:      values["MemFree"],
+		Buffers:   values["Buffers"],
+		Cached:    values["Cached"],
+		SwapTotal: values["SwapTotal"],
+		SwapFree:  values["SwapFree"],
+	}
+
+	available, ok := values["MemAvailable"]
+	if !ok {
+		// Kernels before 3.14 do not estimate the available memory
+		available = memory.Free + memory.Buffers + memory.Cached
+	}
+	memory.Available = available
+	memory.Used = memory.Total - memory.Available
+	memory.SwapUsed = memory.SwapTotal - memory.SwapFree
+	return memory, nil
+}
+
+// Returns the load averages from /proc/loadavg.
+func (h HostInfoCollector) Load() (LoadAverage, error) {
+	content, err := h.readFile("proc/loadavg")
+	if err != nil {
+		return LoadAverage{}, err
+	}
+
+	fields := strings.Fields(content)
+	if len(fields) < 4 {
+		return LoadAverage{}, fmt.Errorf("unexpected format of %s: %q", h.path("proc/loadavg"), content)
+	}
+
+	var load LoadAverage
+	var errs [5]error
+	load.Load1, errs[0] = strconv.ParseFloat(fields[0], 64)
+	load.Load5, errs[1] = strconv.ParseFloat(fields[1], 64)
+	load.Load15, errs[2] = strconv.ParseFloat(fields[2], 64)
+	running, total, _ := strings.Cut(fields[3], "/")
+	load.Running, errs[3] = strconv.Atoi(running)
+	load.Total, errs[4] = strconv.Atoi(total)
+
+	if err := errors.Join(errs[:]...); err != nil {
+		return LoadAverage{}, fmt.Errorf("unexpected format of %s: %w", h.path("proc/loadavg"), err)
+	}
+	return load, nil
+}
+
+// Returns the time since the host booted from /proc/uptime.
+func (h HostInfoCollector) Uptime() (time.Duration, error) {
+	content, err := h.readFile("proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected format of %s: %q", h.path("proc/uptime"), content)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected format of %s: %w", h.path("proc/uptime"), err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Returns the moment the host booted from the btime line of /proc/stat.
+func (h HostInfoCollector) BootTime() (time.Time, error) {
+	content, err := h.readFile("proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		value, ok := strings.CutPrefix(line, "btime ")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unexpected format of %s: %w", h.path("proc/stat"), err)
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("no btime in %s", h.path("proc/stat"))
+}
+
+// Returns the path of the file below the root of the collector.
+func (h HostInfoCollector) path(name string) string {
+	return rootPath(h.Root, name)
+}
+
+func (h HostInfoCollector) readFile(name string) (string, error) {
+	content, err := os.ReadFile(h.path(name))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// Joins a path below the root directory of a collector, "/" if the root is empty.
+func rootPath(root, name string) string {
+	if root == "" {
+		root = "/"
+	}
+	return filepath.Join(root, name)
+}
+
+// Counts the processors in a kernel CPU list like "0-3,6,8-11".
+func countCPUList(list string) (int, error) {
+	count := 0
+	for _, part := range strings.Split(list, ",") {
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return 0, err
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil {
+				return 0, err
+			}
+		}
+		if end < start {
+			return 0, fmt.Errorf("invalid CPU range %q", part)
+		}
+		count += end - start + 1
+	}
+	return count, nil
+}
+
+// Removes the quotes and shell escapes from a value of the os-release file.
+func unquoteOSReleaseValue(value string) string {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+
+	value = value[1 : len(value)-1]
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) && strings.IndexByte("\"\\$`", value[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Creates the files below root, creating parent directories as needed.
+func writeFixture(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create fixture directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write fixture %s: %v", name, err)
+		}
+	}
+}
+
+func TestHostInfoCollector(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"proc/sys/kernel/hostname":  "build-01\n",
+		"proc/sys/kernel/ostype":    "Linux\n",
+		"proc/sys/kernel/osrelease": "6.1.0-13-amd64\n",
+		"proc/sys/kernel/version":   "#1 SMP PREEMPT_DYNAMIC Debian 6.1.55-1 (2023-09-29)\n",
+		"etc/os-release": `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"
+NAME="Debian GNU/Linux"
+VERSION_ID="12"
+VERSION="12 (bookworm)"
+VERSION_CODENAME=bookworm
+ID=debian
+ID_LIKE='ubuntu fedora'
+# comment
+HOME_URL="https://www.debian.org/ \"quoted\""
+`,
+		"proc/cpuinfo": `processor	: 0
+vendor_id	: GenuineIntel
+model name	: Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz
+cpu MHz		: 2394.454
+physical id	: 0
+core id		: 0
+
+processor	: 1
+vendor_id	: GenuineIntel
+model name	: Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz
+cpu MHz		: 2400.000
+physical id	: 0
+core id		: 0
+
+processor	: 2
+vendor_id	: GenuineIntel
+model name	: Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz
+physical id	: 1
+core id		: 0
+
+processor	: 3
+vendor_id	: GenuineIntel
+model name	: Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz
+physical id	: 1
+core id		: 1
+`,
+		"sys/devices/system/cpu/online": "0-1,3\n",
+		"proc/meminfo": `MemTotal:        8000000 kB
+MemFree:         1000000 kB
+MemAvailable:    5000000 kB
+Buffers:          200000 kB
+Cached:          3000000 kB
+SwapTotal:       2000000 kB
+SwapFree:        1500000 kB
+HugePages_Total:       0
+`,
+		"proc/loadavg": "0.52 0.58 0.59 3/467 12345\n",
+		"proc/uptime":  "350735.47 234388.90\n",
+		"proc/stat":    "cpu  1 2 3 4\nbtime 1697520000\nprocesses 1234\n",
+	})
+
+	info, err := HostInfoCollector{Root: root}.Collect()
+	if err != nil {
+		t.Fatalf("Failed to collect host info: %v", err)
+	}
+
+	if info.Hostname != "build-01" {
+		t.Errorf("Unexpected hostname. Expected: %s, Got: %s", "build-01", info.Hostname)
+	}
+	if info.Kernel.Name != "Linux" || info.Kernel.Release != "6.1.0-13-amd64" {
+		t.Errorf("Unexpected kernel: %+v", info.Kernel)
+	}
+
+	if info.OS.ID != "debian" || info.OS.VersionID != "12" || info.OS.VersionCodename != "bookworm" ||
+		info.OS.PrettyName != "Debian GNU/Linux 12 (bookworm)" || len(info.OS.IDLike) != 2 {
+		t.Errorf("Unexpected OS release: %+v", info.OS)
+	}
+	if url := info.OS.Fields["HOME_URL"]; url != `https://www.debian.org/ "quoted"` {
+		t.Errorf("Unexpected unescaping. Expected: %s, Got: %s", `https://www.debian.org/ "quoted"`, url)
+	}
+
+	expectedCPU := CPUInfo{
+		ModelName:     "Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz",
+		Vendor:        "GenuineIntel",
+		Sockets:       2,
+		PhysicalCores: 3,
+		LogicalCores:  4,
+		OnlineCores:   3,
+		MHz:           2394.454,
+	}
+	if info.CPU != expectedCPU {
+		t.Errorf("Unexpected CPU info. Expected: %+v, Got: %+v", expectedCPU, info.CPU)
+	}
+
+	expectedMemory := MemoryInfo{
+		Total:     8000000 * 1024,
+		Free:      1000000 * 1024,
+		Available: 5000000 * 1024,
+		Used:      3000000 * 1024,
+		Buffers:   200000 * 1024,
+		Cached:    3000000 * 1024,
+		SwapTotal: 2000000 * 1024,
+		SwapFree:  1500000 * 1024,
+		SwapUsed:  500000 * 1024,
+	}
+	if info.Memory != expectedMemory {
+		t.Errorf("Unexpected memory info. Expected: %+v, Got: %+v", expectedMemory, info.Memory)
+	}
+
+	expectedLoad := LoadAverage{Load1: 0.52, Load5: 0.58, Load15: 0.59, Running: 3, Total: 467}
+	if info.Load != expectedLoad {
+		t.Errorf("Unexpected load. Expected: %+v, Got: %+v", expectedLoad, info.Load)
+	}
+
+	if info.Uptime != 350735470*time.Millisecond {
+		t.Errorf("Unexpected uptime. Expected: %v, Got: %v", 350735470*time.Millisecond, info.Uptime)
+	}
+	if !info.BootTime.Equal(time.Unix(1697520000, 0)) {
+		t.Errorf("Unexpected boot time: %v", info.BootTime)
+	}
+}
+
+func TestHostInfoCollectorPartial(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"etc/hostname": "fallback\n",
+		"proc/meminfo": "MemTotal: 1000 kB\nMemFree: 100 kB\nBuffers: 50 kB\nCached: 250 kB\n",
+		"proc/loadavg": "garbage\n",
+	})
+
+	collector := HostInfoCollector{Root: root}
+	info, err := collector.Collect()
+	if err == nil {
+		t.Fatal("Expected an error for the missing files")
+	}
+	if info.Hostname != "fallback" {
+		t.Errorf("Expected the hostname from /etc/hostname, got %q", info.Hostname)
+	}
+	// Without MemAvailable the available memory is estimated
+	if info.Memory.Available != 400*1024 || info.Memory.Used != 600*1024 {
+		t.Errorf("Unexpected memory estimate: %+v", info.Memory)
+	}
+
+	if _, err := collector.Load(); err == nil {
+		t.Error("Expected an error for a malformed loadavg")
+	}
+}
+
+func TestCollectHostInfo(t *testing.T) {
+	if _, err := os.Stat("/proc/uptime"); err != nil {
+		t.Skip("No /proc filesystem")
+	}
+
+	info, err := CollectHostInfo()
+	if info.CPU.LogicalCores == 0 || info.Memory.Total == 0 || info.Uptime <= 0 || info.BootTime.IsZero() {
+		t.Errorf("Incomplete host info: %+v, error: %v", info, err)
+	}
+}
@@ -0,0 +1,318 @@
+package system
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// A Linux capability, see capabilities(7).
+type Capability int
+
+// The capabilities known to this package, named like capsh(1) names them.
+const (
+	CapChown Capability = iota
+	CapDacOverride
+	CapDacReadSearch
+	CapFowner
+	CapFsetid
+	CapKill
+	CapSetgid
+	CapSetuid
+	CapSetpcap
+	CapLinuxImmutable
+	CapNetBindService
+	CapNetBroadcast
+	CapNetAdmin
+	CapNetRaw
+	CapIPCLock
+	CapIPCOwner
+	CapSysModule
+	CapSysRawio
+	CapSysChroot
+	CapSysPtrace
+	CapSysPacct
+	CapSysAdmin
+	CapSysBoot
+	CapSysNice
+	CapSysResource
+	CapSysTime
+	CapSysTTYConfig
+	CapMknod
+	CapLease
+	CapAuditWrite
+	CapAuditControl
+	CapSetfcap
+	CapMacOverride
+	CapMacAdmin
+	CapSyslog
+	CapWakeAlarm
+	CapBlockSuspend
+	CapAuditRead
+	CapPerfmon
+	CapBPF
+	CapCheckpointRestore
+)
+
+var capabilityNames = []string{
+	"cap_chown", "cap_dac_override", "cap_dac_read_search", "cap_fowner", "cap_fsetid",
+	"cap_kill", "cap_setgid", "cap_setuid", "cap_setpcap", "cap_linux_immutable",
+	"cap_net_bind_service", "cap_net_broadcast", "cap_net_admin", "cap_net_raw", "cap_ipc_lock",
+	"cap_ipc_owner", "cap_sys_module", "cap_sys_rawio", "cap_sys_chroot", "cap_sys_ptrace",
+	"cap_sys_pacct", "cap_sys_admin", "cap_sys_boot", "cap_sys_nice", "cap_sys_resource",
+	"cap_sys_time", "cap_sys_tty_config", "cap_mknod", "cap_lease", "cap_audit_write",
+	"cap_audit_control", "cap_setfcap", "cap_mac_override", "cap_mac_admin", "cap_syslog",
+	"cap_wake_alarm", "cap_block_suspend", "cap_audit_read", "cap_perfmon", "cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// A set of capabilities as a bit mask, bit n representing Capability n.
+type CapabilitySet uint64
+
+// Describes the capability sets of a process.
+//
+// Fields:
+//   - Inheritable: CapabilitySet - preserved across execve, CapInh
+//   - Permitted: CapabilitySet - the capabilities the process may make effective, CapPrm
+//   - Effective: CapabilitySet - the capabilities the kernel checks, CapEff
+//   - Bounding: CapabilitySet - the limit of capabilities gained through execve, CapBnd
+//   - Ambient: CapabilitySet - preserved across execve of unprivileged programs, CapAmb
+type Capabilities struct {
+	Inheritable CapabilitySet `json:"inheritable" bson:"inheritable" yaml:"inheritable"`
+	Permitted   CapabilitySet `json:"permitted" bson:"permitted" yaml:"permitted"`
+	Effective   CapabilitySet `json:"effective" bson:"effective" yaml:"effective"`
+	Bounding    CapabilitySet `json:"bounding" bson:"bounding" yaml:"bounding"`
+	Ambient     CapabilitySet `json:"ambient" bson:"ambient" yaml:"ambient"`
+}
+
+// Describes the user and groups the current process runs as.
+//
+// Fields:
+//   - UID: int - the real user id
+//   - EUID: int - the effective user id, used for permission checks
+//   - GID: int - the real group id
+//   - EGID: int - the effective group id
+//   - Groups: []int - all groups of the process, the effective and the supplementary ones
+//   - Username: string - the name of the effective user, empty if it has no entry in the user database
+//   - GroupName: string - the name of the effective group, empty if it has no entry in the group database
+//   - GroupNames: []string - the names of Groups, ids without an entry are left out
+type Identity struct {
+	UID        int      `json:"uid" bson:"uid" yaml:"uid"`
+	EUID       int      `json:"euid" bson:"euid" yaml:"euid"`
+	GID        int      `json:"gid" bson:"gid" yaml:"gid"`
+	EGID       int      `json:"egid" bson:"egid" yaml:"egid"`
+	Groups     []int    `json:"groups" bson:"groups" yaml:"groups"`
+	Username   string   `json:"username" bson:"username" yaml:"username"`
+	GroupName  string   `json:"group_name" bson:"group_name" yaml:"group_name"`
+	GroupNames []string `json:"group_names" bson:"group_names" yaml:"group_names"`
+}
+
+// Returns the name of the capability, e.g. "cap_net_admin".
+func (c Capability) String() string {
+	if c >= 0 && int(c) < len(capabilityNames) {
+		return capabilityNames[c]
+	}
+	return "cap_" + strconv.Itoa(int(c))
+}
+
+// Returns true if the set contains the capability.
+func (s CapabilitySet) Has(capability Capability) bool {
+	return capability >= 0 && capability < 64 && s&(1<<uint(capability)) != 0
+}
+
+// Returns the capabilities in the set, in ascending order.
+func (s CapabilitySet) List() []Capability {
+	var capabilities []Capability
+	for capability := Capability(0); capability < 64; capability++ {
+		if s.Has(capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return capabilities
+}
+
+// Returns the names of the capabilities in the set, in ascending order.
+func (s CapabilitySet) Names() []string {
+	capabilities := s.List()
+	names := make([]string, len(capabilities))
+	for i, capability := range capabilities {
+		names[i] = capability.String()
+	}
+	return names
+}
+
+// Returns true if the effective set contains the capability, i.e. the process can
+// perform the operations it guards right now.
+func (c *Capabilities) Can(capability Capability) bool {
+	return c.Effective.Has(capability)
+}
+
+// Returns the capability sets of the current process from /proc/self/status.
+// Only supported on Linux.
+//
+// Returns:
+//   - *Capabilities: the capability sets
+//   - error: if the status file could not be read or parsed
+//
+// Example usage:
+//
+//	capabilities, err := ReadCapabilities()
+//	if err == nil && !capabilities.Can(CapNetBindService) && port < 1024 {
+//	  log.Fatalf("cannot bind port %d, grant cap_net_bind_service", port)
+//	}
+func ReadCapabilities() (*Capabilities, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseCapabilities(file)
+}
+
+// Parses the capability sets from the format of /proc/<pid>/status. Sets missing from the
+// input, e.g. CapAmb on old kernels, are empty.
+//
+// Parameters:
+//   - r: io.Reader - the content of a status file
+//
+// Returns:
+//   - *Capabilities: the capability sets
+//   - error: if a set is malformed or the effective set is missing
+func ParseCapabilities(r io.Reader) (*Capabilities, error) {
+	capabilities := &Capabilities{}
+	sets := map[string]*CapabilitySet{
+		"CapInh": &capabilities.Inheritable,
+		"CapPrm": &capabilities.Permitted,
+		"CapEff": &capabilities.Effective,
+		"CapBnd": &capabilities.Bounding,
+		"CapAmb": &capabilities.Ambient,
+	}
+
+	found := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		set, known := sets[key]
+		if !ok || !known {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in status: %q", key, strings.TrimSpace(value))
+		}
+		*set = CapabilitySet(mask)
+		found = found || key == "CapEff"
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read status: %w", err)
+	}
+	if !found {
+		return nil, errors.New("no CapEff in status")
+	}
+	return capabilities, nil
+}
+
+// Returns the user and groups of the current process. Names are resolved through the
+// user and group databases, ids without an entry are reported without a name. Only
+// supported on Unix-based systems.
+//
+// Returns:
+//   - *Identity: the identity of the process
+//   - error: if the groups of the process could not be determined
+//
+// Example usage:
+//
+//	identity, err := CurrentIdentity()
+//	if err == nil && !identity.IsRoot() && !identity.InGroup("docker") {
+//	  log.Fatal("run as root or as a member of the docker group")
+//	}
+func CurrentIdentity() (*Identity, error) {
+	identity := &Identity{
+		UID:  os.Getuid(),
+		EUID: os.Geteuid(),
+		GID:  os.Getgid(),
+		EGID: os.Getegid(),
+	}
+
+	groups, err := os.Getgroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+	identity.Groups = append(identity.Groups, identity.EGID)
+	for _, gid := range groups {
+		if !containsInt(identity.Groups, gid) {
+			identity.Groups = append(identity.Groups, gid)
+		}
+	}
+
+	if u, err := user.LookupId(strconv.Itoa(identity.EUID)); err == nil {
+		identity.Username = u.Username
+	}
+	for _, gid := range identity.Groups {
+		g, err := user.LookupGroupId(strconv.Itoa(gid))
+		if err != nil {
+			continue
+		}
+		if gid == identity.EGID {
+			identity.GroupName = g.Name
+		}
+		identity.GroupNames = append(identity.GroupNames, g.Name)
+	}
+	return identity, nil
+}
+
+// Returns true if the effective user is root.
+func (i *Identity) IsRoot() bool {
+	return i.EUID == 0
+}
+
+// Returns true if the process is a member of the group, given by name or gid.
+func (i *Identity) InGroup(group string) bool {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return containsInt(i.Groups, gid)
+	}
+	return containsString(i.GroupNames, group)
+}
+
+// Returns true if sudo is installed and allows the current user to run commands without
+// asking for a password, checked by running 'sudo -n true' with the DefaultExecutor.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of the sudo command
+//
+// Returns:
+//   - bool: true if privileged commands can be run through sudo non-interactively
+//   - error: if sudo could not be run for another reason than requiring a password,
+//     e.g. because the context expired
+//
+// Example usage:
+//
+//	if ok, _ := SudoAvailable(ctx); !ok {
+//	  log.Println("sudo requires a password, skipping the package upgrade")
+//	}
+func SudoAvailable(ctx context.Context) (bool, error) {
+	path, err := LookupCommand("sudo")
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	result, err := NewCommand(path, "-n", "true").Run(ctx)
+	if err == nil {
+		return true, nil
+	}
+	if result != nil && result.ExitCode > 0 && ctx.Err() == nil {
+		// sudo exits with 1 if a password is required or the user may not use it
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,111 @@
+package system
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	capabilities, err := ParseCapabilities(strings.NewReader(`Name:	agent
+Umask:	0022
+State:	S (sleeping)
+Uid:	0	0	0	0
+CapInh:	0000000000000000
+CapPrm:	00000000a80425fb
+CapEff:	00000000a80425fb
+CapBnd:	000001ffffffffff
+CapAmb:	0000000000000400
+NoNewPrivs:	0
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse capabilities: %v", err)
+	}
+
+	expected := "cap_chown,cap_dac_override,cap_fowner,cap_fsetid,cap_kill,cap_setgid,cap_setuid,cap_setpcap," +
+		"cap_net_bind_service,cap_net_raw,cap_sys_chroot,cap_mknod,cap_audit_write,cap_setfcap"
+	if names := strings.Join(capabilities.Effective.Names(), ","); names != expected {
+		t.Errorf("Unexpected effective capabilities. Expected: %s, Got: %s", expected, names)
+	}
+	if capabilities.Permitted != capabilities.Effective || capabilities.Inheritable != 0 {
+		t.Errorf("Unexpected permitted or inheritable set: %+v", capabilities)
+	}
+	if !capabilities.Can(CapNetBindService) || capabilities.Can(CapSysAdmin) || capabilities.Can(Capability(-1)) {
+		t.Error("Unexpected result of Can")
+	}
+	if len(capabilities.Bounding.List()) != 41 || !capabilities.Bounding.Has(CapCheckpointRestore) {
+		t.Errorf("Expected all 41 capabilities in the bounding set, got %v", capabilities.Bounding.Names())
+	}
+	if names := capabilities.Ambient.Names(); len(names) != 1 || names[0] != "cap_net_bind_service" {
+		t.Errorf("Unexpected ambient capabilities: %v", names)
+	}
+	if name := CapabilitySet(1 << 45).Names(); name[0] != "cap_45" {
+		t.Errorf("Expected unknown capabilities to be named by number, got %v", name)
+	}
+
+	for _, status := range []string{"Name:\tagent\n", "CapEff:\tnot-hex\n"} {
+		if _, err := ParseCapabilities(strings.NewReader(status)); err == nil {
+			t.Errorf("Expected an error for %q", status)
+		}
+	}
+}
+
+func TestReadCapabilities(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Capabilities are only supported on linux")
+	}
+	capabilities, err := ReadCapabilities()
+	if err != nil {
+		t.Fatalf("Failed to read capabilities: %v", err)
+	}
+	if capabilities.Effective&^capabilities.Permitted != 0 {
+		t.Errorf("Expected the effective set to be a subset of the permitted one: %+v", capabilities)
+	}
+}
+
+func TestCurrentIdentity(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Identities are only supported on unix")
+	}
+	identity, err := CurrentIdentity()
+	if err != nil {
+		t.Fatalf("Failed to get identity: %v", err)
+	}
+
+	if identity.UID != os.Getuid() || identity.EUID != os.Geteuid() || identity.GID != os.Getgid() || identity.EGID != os.Getegid() {
+		t.Errorf("Unexpected ids: %+v", identity)
+	}
+	if len(identity.Groups) == 0 || identity.Groups[0] != identity.EGID || !identity.InGroup(strconv.Itoa(identity.EGID)) {
+		t.Errorf("Expected the effective group to be listed first: %+v", identity)
+	}
+	if identity.GroupName != "" && !identity.InGroup(identity.GroupName) {
+		t.Errorf("Expected to be a member of %s", identity.GroupName)
+	}
+	if identity.IsRoot() != (os.Geteuid() == 0) || identity.InGroup("no-such-group") {
+		t.Errorf("Unexpected privileges: %+v", identity)
+	}
+}
+
+func TestSudoAvailable(t *testing.T) {
+	fake := NewFakeExecutor()
+	restore := SetDefaultExecutor(fake)
+	defer restore()
+	ctx := context.Background()
+
+	if ok, err := SudoAvailable(ctx); ok || err != nil {
+		t.Errorf("Expected sudo to be unavailable if it is not installed, got %v (%v)", ok, err)
+	}
+
+	fake.AddPath("sudo", "/usr/bin/sudo")
+	fake.On(`^/usr/bin/sudo -n true$`).Times(1).ExitCode(1).Stderr("sudo: a password is required\n")
+	fake.On(`^/usr/bin/sudo -n true$`)
+	if ok, err := SudoAvailable(ctx); ok || err != nil {
+		t.Errorf("Expected sudo to be unavailable if it requires a password, got %v (%v)", ok, err)
+	}
+	if ok, err := SudoAvailable(ctx); !ok || err != nil {
+		t.Errorf("Expected sudo to be available, got %v (%v)", ok, err)
+	}
+}
@@ -0,0 +1,250 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Describes a mount from /proc/self/mountinfo.
+//
+// Fields:
+//   - ID: int - the unique id of the mount
+//   - ParentID: int - the id of the parent mount
+//   - Major: int - the major device number of the filesystem
+//   - Minor: int - the minor device number of the filesystem
+//   - Root: string - the directory of the filesystem forming the root of this mount
+//   - MountPoint: string - where the filesystem is mounted
+//   - Options: []string - the per-mount options, e.g. "rw" and "noatime"
+//   - Optional: []string - the optional fields, e.g. "shared:1"
+//   - FSType: string - the filesystem type, e.g. "ext4" or "overlay"
+//   - Source: string - the mounted device or "none"
+//   - SuperOptions: []string - the per-filesystem options
+type MountInfo struct {
+	ID           int      `json:"id" bson:"id" yaml:"id"`
+	ParentID     int      `json:"parent_id" bson:"parent_id" yaml:"parent_id"`
+	Major        int      `json:"major" bson:"major" yaml:"major"`
+	Minor        int      `json:"minor" bson:"minor" yaml:"minor"`
+	Root         string   `json:"root" bson:"root" yaml:"root"`
+	MountPoint   string   `json:"mount_point" bson:"mount_point" yaml:"mount_point"`
+	Options      []string `json:"options" bson:"options" yaml:"options"`
+	Optional     []string `json:"optional" bson:"optional" yaml:"optional"`
+	FSType       string   `json:"fs_type" bson:"fs_type" yaml:"fs_type"`
+	Source       string   `json:"source" bson:"source" yaml:"source"`
+	SuperOptions []string `json:"super_options" bson:"super_options" yaml:"super_options"`
+}
+
+// Describes the capacity of a filesystem. Sizes are in bytes.
+//
+// Fields:
+//   - Path: string - the path the usage was queried for
+//   - Total: uint64 - the size of the filesystem
+//   - Free: uint64 - the free space, including the space reserved for root
+//   - Available: uint64 - the space available to unprivileged users
+//   - Used: uint64 - Total minus Free
+//   - UsedPercent: float64 - the used share of the space available to users, like df reports it
+//   - Inodes: uint64 - the number of inodes, 0 if the filesystem has no fixed number
+//   - InodesFree: uint64 - the number of free inodes
+//   - InodesUsed: uint64 - Inodes minus InodesFree
+//   - ReadOnly: bool - true if the filesystem is mounted read-only
+type DiskUsage struct {
+	Path        string  `json:"path" bson:"path" yaml:"path"`
+	Total       uint64  `json:"total" bson:"total" yaml:"total"`
+	Free        uint64  `json:"free" bson:"free" yaml:"free"`
+	Available   uint64  `json:"available" bson:"available" yaml:"available"`
+	Used        uint64  `json:"used" bson:"used" yaml:"used"`
+	UsedPercent float64 `json:"used_percent" bson:"used_percent" yaml:"used_percent"`
+	Inodes      uint64  `json:"inodes" bson:"inodes" yaml:"inodes"`
+	InodesFree  uint64  `json:"inodes_free" bson:"inodes_free" yaml:"inodes_free"`
+	InodesUsed  uint64  `json:"inodes_used" bson:"inodes_used" yaml:"inodes_used"`
+	ReadOnly    bool    `json:"read_only" bson:"read_only" yaml:"read_only"`
+}
+
+// Returns true if the mount is read-only.
+func (m *MountInfo) ReadOnly() bool {
+	return containsString(m.Options, "ro")
+}
+
+// Returns the mounts of the current process from /proc/self/mountinfo. Only supported on Linux.
+//
+// Returns:
+//   - []MountInfo: the mounts in the order they were mounted
+//   - error: if the mount table could not be read
+//
+// Example usage:
+//
+//	mounts, err := ListMounts()
+//	for _, mount := range mounts {
+//	  fmt.Printf("%s on %s type %s\n", mount.Source, mount.MountPoint, mount.FSType)
+//	}
+func ListMounts() ([]MountInfo, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseMountInfo(file)
+}
+
+// Parses the format of /proc/<pid>/mountinfo. Octal escapes like "\040" for spaces in
+// paths are decoded.
+//
+// Parameters:
+//   - r: io.Reader - the content of a mountinfo file
+//
+// Returns:
+//   - []MountInfo: the mounts in the order of the input
+//   - error: if a line is malformed or the input could not be read
+func ParseMountInfo(r io.Reader) ([]MountInfo, error) {
+	var mounts []MountInfo
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		mount, err := parseMountInfoLine(line)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, mount)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mountinfo: %w", err)
+	}
+	return mounts, nil
+}
+
+// Returns the mount containing the path, i.e. the one with the longest mount point that is
+// a parent of the path. If several filesystems are mounted on the same mount point, the last
+// one hides the others and is returned. The path has to be absolute and clean, symbolic
+// links are not resolved. Returns nil if no mount matches.
+func FindMount(mounts []MountInfo, path string) *MountInfo {
+	var found *MountInfo
+	for i := range mounts {
+		mount := &mounts[i]
+		if !pathWithin(path, mount.MountPoint) {
+			continue
+		}
+		if found == nil || len(mount.MountPoint) >= len(found.MountPoint) {
+			found = mount
+		}
+	}
+	return found
+}
+
+// Returns the mount containing the path, resolving symbolic links first. Only supported on Linux.
+//
+// Parameters:
+//   - path: string - any existing file or directory
+//
+// Returns:
+//   - *MountInfo: the mount, with the mount point and filesystem type
+//   - error: if the path does not exist or the mount table could not be read
+//
+// Example usage:
+//
+//	mount, err := MountForPath("/var/lib/app/data")
+//	if err == nil && mount.FSType == "nfs4" {
+//	  log.Println("data directory is on NFS, disabling file locks")
+//	}
+func MountForPath(path string) (*MountInfo, error) {
+	resolved, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err = filepath.EvalSymlinks(resolved); err != nil {
+		return nil, err
+	}
+
+	mounts, err := ListMounts()
+	if err != nil {
+		return nil, err
+	}
+	mount := FindMount(mounts, resolved)
+	if mount == nil {
+		return nil, fmt.Errorf("no mount found for %s", resolved)
+	}
+	return mount, nil
+}
+
+// Parses a single line of a mountinfo file, e.g.
+// "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue".
+func parseMountInfoLine(line string) (MountInfo, error) {
+	fields := strings.Fields(line)
+	separator := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			separator = i
+			break
+		}
+	}
+	if separator < 0 || len(fields) < separator+3 {
+		return MountInfo{}, fmt.Errorf("invalid mountinfo line: %q", line)
+	}
+
+	var mount MountInfo
+	var err error
+	if mount.ID, err = strconv.Atoi(fields[0]); err != nil {
+		return MountInfo{}, fmt.Errorf("invalid mount id in mountinfo line: %q", line)
+	}
+	if mount.ParentID, err = strconv.Atoi(fields[1]); err != nil {
+		return MountInfo{}, fmt.Errorf("invalid parent id in mountinfo line: %q", line)
+	}
+	major, minor, ok := strings.Cut(fields[2], ":")
+	if !ok {
+		return MountInfo{}, fmt.Errorf("invalid device in mountinfo line: %q", line)
+	}
+	if mount.Major, err = strconv.Atoi(major); err != nil {
+		return MountInfo{}, fmt.Errorf("invalid device in mountinfo line: %q", line)
+	}
+	if mount.Minor, err = strconv.Atoi(minor); err != nil {
+		return MountInfo{}, fmt.Errorf("invalid device in mountinfo line: %q", line)
+	}
+
+	mount.Root = unescapeMountField(fields[3])
+	mount.MountPoint = unescapeMountField(fields[4])
+	mount.Options = strings.Split(fields[5], ",")
+	mount.Optional = append([]string{}, fields[6:separator]...)
+	mount.FSType = unescapeMountField(fields[separator+1])
+	mount.Source = unescapeMountField(fields[separator+2])
+	if len(fields) > separator+3 {
+		mount.SuperOptions = strings.Split(fields[separator+3], ",")
+	}
+	return mount, nil
+}
+
+// Decodes the octal escapes the kernel uses for space, tab, newline and backslash.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) && isOctal(field[i+1]) && isOctal(field[i+2]) && isOctal(field[i+3]) {
+			b.WriteByte((field[i+1]-'0')<<6 | (field[i+2]-'0')<<3 | (field[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// Returns true if the path is the directory or lies below it.
+func pathWithin(path, dir string) bool {
+	if dir == "/" || path == dir {
+		return true
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
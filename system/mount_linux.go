@@ -0,0 +1,57 @@
+//go:build linux
+
+package system
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// The flag of statfs reporting a read-only mount.
+const statfsReadOnly = 0x1
+
+// Returns the capacity and inode usage of the filesystem containing the path.
+// Only supported on Linux.
+//
+// Parameters:
+//   - path: string - any file or directory on the filesystem
+//
+// Returns:
+//   - *DiskUsage: the usage of the filesystem
+//   - error: if the path does not exist or the filesystem could not be queried
+//
+// Example usage:
+//
+//	usage, err := GetDiskUsage("/var/lib/app")
+//	if err == nil && usage.Available < 1<<30 {
+//	  log.Printf("less than 1 GiB left on %s (%.1f%% used)", usage.Path, usage.UsedPercent)
+//	}
+func GetDiskUsage(path string) (*DiskUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return nil, fmt.Errorf("failed to query filesystem of %s: %w", path, err)
+	}
+
+	blockSize := uint64(stat.Frsize)
+	if blockSize == 0 {
+		blockSize = uint64(stat.Bsize)
+	}
+
+	usage := &DiskUsage{
+		Path:       path,
+		Total:      stat.Blocks * blockSize,
+		Free:       stat.Bfree * blockSize,
+		Available:  stat.Bavail * blockSize,
+		Inodes:     stat.Files,
+		InodesFree: stat.Ffree,
+		ReadOnly:   stat.Flags&statfsReadOnly != 0,
+	}
+	usage.Used = usage.Total - usage.Free
+	usage.InodesUsed = usage.Inodes - usage.InodesFree
+
+	// Like df, relate the used space to what users can use, excluding the reserved blocks
+	if usable := usage.Used + usage.Available; usable > 0 {
+		usage.UsedPercent = float64(usage.Used) / float64(usable) * 100
+	}
+	return usage, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package system
+
+import (
+	"errors"
+)
+
+var errDiskUsageUnsupported = errors.New("disk usage is only supported on linux")
+
+func GetDiskUsage(path string) (*DiskUsage, error) {
+	return nil, errDiskUsageUnsupported
+}
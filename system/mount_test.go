@@ -0,0 +1,111 @@
+package system
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const mountInfoFixture = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+24 22 0:22 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+40 22 8:17 / /var/lib rw,noatime shared:20 - xfs /dev/sdb1 rw,attr2,inode64
+41 40 0:45 / /var/lib/app\040data ro,relatime shared:21 master:3 - nfs4 nas:/export/app\134data rw,vers=4.2
+42 22 0:50 / /mnt rw,relatime - tmpfs tmpfs rw,size=1024k
+43 22 0:51 / /mnt rw,relatime - tmpfs overlay rw,size=2048k
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := ParseMountInfo(strings.NewReader(mountInfoFixture))
+	if err != nil {
+		t.Fatalf("Failed to parse mountinfo: %v", err)
+	}
+	if len(mounts) != 7 {
+		t.Fatalf("Expected 7 mounts, got %d", len(mounts))
+	}
+
+	nfs := mounts[4]
+	if nfs.ID != 41 || nfs.ParentID != 40 || nfs.Major != 0 || nfs.Minor != 45 || nfs.Root != "/" {
+		t.Errorf("Unexpected ids of the NFS mount: %+v", nfs)
+	}
+	if nfs.MountPoint != "/var/lib/app data" || nfs.Source != `nas:/export/app\data` || nfs.FSType != "nfs4" {
+		t.Errorf("Expected escapes to be decoded, got %q from %q", nfs.MountPoint, nfs.Source)
+	}
+	if strings.Join(nfs.Optional, " ") != "shared:21 master:3" || strings.Join(nfs.SuperOptions, ",") != "rw,vers=4.2" {
+		t.Errorf("Unexpected optional fields or super options: %+v", nfs)
+	}
+	if !nfs.ReadOnly() || mounts[0].ReadOnly() {
+		t.Error("Expected only the NFS mount to be read-only")
+	}
+	if len(mounts[5].Optional) != 0 {
+		t.Errorf("Expected no optional fields, got %v", mounts[5].Optional)
+	}
+
+	for _, line := range []string{
+		"22 1 8:1 / / rw,relatime shared:1 ext4 /dev/sda1 rw",
+		"x 1 8:1 / / rw - ext4 /dev/sda1 rw",
+		"22 1 8-1 / / rw - ext4 /dev/sda1 rw",
+		"22 1 8:1 / / rw -",
+	} {
+		if _, err := ParseMountInfo(strings.NewReader(line)); err == nil {
+			t.Errorf("Expected an error for %q", line)
+		}
+	}
+}
+
+func TestFindMount(t *testing.T) {
+	mounts, _ := ParseMountInfo(strings.NewReader(mountInfoFixture))
+
+	tests := map[string]string{
+		"/":                        "/",
+		"/etc/hosts":               "/",
+		"/var/lib":                 "/var/lib",
+		"/var/lib/app data/report": "/var/lib/app data",
+		"/var/lib/app":             "/var/lib",
+		"/var/library":             "/",
+		"/proc/self":               "/proc",
+	}
+	for path, expected := range tests {
+		mount := FindMount(mounts, path)
+		if mount == nil || mount.MountPoint != expected {
+			t.Errorf("Unexpected mount for %s. Expected: %s, Got: %+v", path, expected, mount)
+		}
+	}
+
+	// The filesystem mounted last hides the ones below it
+	if mount := FindMount(mounts, "/mnt/file"); mount == nil || mount.ID != 43 {
+		t.Errorf("Expected the topmost mount on /mnt, got %+v", mount)
+	}
+	if mount := FindMount(nil, "/"); mount != nil {
+		t.Errorf("Expected no mount without mounts, got %+v", mount)
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Disk usage is only supported on linux")
+	}
+
+	dir := t.TempDir()
+	usage, err := GetDiskUsage(dir)
+	if err != nil {
+		t.Fatalf("Failed to query disk usage: %v", err)
+	}
+	if usage.Path != dir || usage.Total == 0 || usage.Available > usage.Free || usage.Free > usage.Total {
+		t.Errorf("Implausible disk usage: %+v", usage)
+	}
+	if usage.Used != usage.Total-usage.Free || usage.UsedPercent < 0 || usage.UsedPercent > 100 {
+		t.Errorf("Inconsistent used space: %+v", usage)
+	}
+	if _, err := GetDiskUsage(dir + "/missing"); err == nil {
+		t.Error("Expected an error for a missing path")
+	}
+
+	mount, err := MountForPath(dir)
+	if err != nil {
+		t.Fatalf("Failed to find the mount of %s: %v", dir, err)
+	}
+	if mount.FSType == "" || !strings.HasPrefix(mount.MountPoint, "/") {
+		t.Errorf("Unexpected mount for %s: %+v", dir, mount)
+	}
+}
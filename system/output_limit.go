@@ -0,0 +1,176 @@
+package system
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Limits how much output of a command is kept in memory.
+//
+// When a stream produces more than HeadBytes+TailBytes bytes, only the first HeadBytes
+// and the last TailBytes are kept in the CommandResult and the stream is flagged as
+// truncated. With SpillToFile the complete output is additionally written to a
+// temporary file, whose path is reported in the result. The caller is responsible for
+// removing that file.
+//
+// Fields:
+//   - HeadBytes: int - the number of bytes kept from the beginning of each stream
+//   - TailBytes: int - the number of bytes kept from the end of each stream
+//   - SpillToFile: bool - write the complete output of each stream to a temporary file
+//   - SpillDir: string - the directory of the temporary files, os.TempDir() if empty
+type OutputLimit struct {
+	HeadBytes   int
+	TailBytes   int
+	SpillToFile bool
+	SpillDir    string
+}
+
+// Limits the output of the command kept in memory.
+//
+// Example usage:
+//
+//	result, err := NewCommand("make", "test").
+//	  WithOutputLimit(OutputLimit{HeadBytes: 4096, TailBytes: 64 * 1024, SpillToFile: true}).
+//	  Run(ctx)
+//	if result.StdoutTruncated {
+//	  fmt.Println("Full log:", result.StdoutFile)
+//	}
+func (c *Command) WithOutputLimit(limit OutputLimit) *Command {
+	c.outputLimit = &limit
+	return c
+}
+
+// Collects the output of one stream of a command.
+type outputCapture interface {
+	io.Writer
+	Bytes() []byte
+	Size() int64
+	Truncated() bool
+	File() string
+	Close() error
+}
+
+// Captures the whole output in memory.
+type unlimitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *unlimitedBuffer) Size() int64     { return int64(b.Len()) }
+func (b *unlimitedBuffer) Truncated() bool { return false }
+func (b *unlimitedBuffer) File() string    { return "" }
+func (b *unlimitedBuffer) Close() error    { return nil }
+
+// Keeps the first and the last bytes of the output and optionally spills everything to a file.
+type cappedBuffer struct {
+	head    []byte
+	headMax int
+	tail    []byte
+	tailMax int
+	size    int64
+	file    *os.File
+	fileErr error
+}
+
+// Creates the capture of one output stream according to the limit.
+func newOutputCapture(limit *OutputLimit, stream OutputStream) (outputCapture, error) {
+	if limit == nil {
+		return &unlimitedBuffer{}, nil
+	}
+
+	b := &cappedBuffer{headMax: limit.HeadBytes, tailMax: limit.TailBytes}
+	if b.headMax < 0 {
+		b.headMax = 0
+	}
+	if b.tailMax < 0 {
+		b.tailMax = 0
+	}
+
+	if limit.SpillToFile {
+		file, err := os.CreateTemp(limit.SpillDir, "command-"+stream.String()+"-*.log")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s spill file: %w", stream, err)
+		}
+		b.file = file
+	}
+	return b, nil
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.size += int64(n)
+
+	// A failing spill file must not break the command, the error is reported on Close
+	if b.file != nil && b.fileErr == nil {
+		_, b.fileErr = b.file.Write(p)
+	}
+
+	if room := b.headMax - len(b.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+
+	if b.tailMax > 0 && len(p) > 0 {
+		b.tail = append(b.tail, p...)
+		// Compact only once the buffer has doubled, keeping writes amortized O(n)
+		if len(b.tail) > 2*b.tailMax {
+			b.tail = append(b.tail[:0], b.tail[len(b.tail)-b.tailMax:]...)
+		}
+	}
+
+	return n, nil
+}
+
+// Returns the retained head followed by the retained tail.
+func (b *cappedBuffer) Bytes() []byte {
+	tail := b.tail
+	if len(tail) > b.tailMax {
+		tail = tail[len(tail)-b.tailMax:]
+	}
+
+	out := make([]byte, 0, len(b.head)+len(tail))
+	out = append(out, b.head...)
+	return append(out, tail...)
+}
+
+// Returns the total number of bytes written, including discarded ones.
+func (b *cappedBuffer) Size() int64 {
+	return b.size
+}
+
+// Returns true if parts of the output were discarded.
+func (b *cappedBuffer) Truncated() bool {
+	return b.size > int64(b.headMax+b.tailMax)
+}
+
+// Returns the path of the spill file, empty if output is not spilled.
+func (b *cappedBuffer) File() string {
+	if b.file == nil {
+		return ""
+	}
+	return b.file.Name()
+}
+
+// Closes the spill file, reporting any error that occurred while writing it.
+func (b *cappedBuffer) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	if b.fileErr != nil {
+		return fmt.Errorf("failed to write spill file: %w", b.fileErr)
+	}
+	return err
+}
+
+// Closes the capture of a command that never ran and removes its spill file.
+func discardCapture(capture outputCapture) {
+	capture.Close()
+	if capture.File() != "" {
+		os.Remove(capture.File())
+	}
+}
@@ -0,0 +1,116 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	capture, err := newOutputCapture(&OutputLimit{HeadBytes: 4, TailBytes: 3}, StreamStdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chunk := range []string{"ab", "cdef", "ghijklmn", "op"} {
+		capture.Write([]byte(chunk))
+	}
+
+	if string(capture.Bytes()) != "abcdnop" {
+		t.Errorf("Unexpected retained output: %q", capture.Bytes())
+	}
+	if capture.Size() != 16 || !capture.Truncated() {
+		t.Errorf("Expected 16 bytes and truncation, got %d and %v", capture.Size(), capture.Truncated())
+	}
+
+	small, _ := newOutputCapture(&OutputLimit{HeadBytes: 4, TailBytes: 3}, StreamStdout)
+	small.Write([]byte("abcdefg"))
+	if string(small.Bytes()) != "abcdefg" || small.Truncated() {
+		t.Errorf("Output fitting the limit must be kept completely, got %q", small.Bytes())
+	}
+}
+
+func TestCommandOutputLimit(t *testing.T) {
+	result, err := NewShellCommand("seq 1 10000; seq 1 3 >&2").
+		WithOutputLimit(OutputLimit{HeadBytes: 8, TailBytes: 11}).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+
+	if result.StdoutString() != "1\n2\n3\n4\n9999\n10000\n" {
+		t.Errorf("Unexpected retained stdout: %q", result.Stdout)
+	}
+	if !result.StdoutTruncated || result.StdoutSize != 48894 {
+		t.Errorf("Expected truncated stdout of 48894 bytes, got %v and %d", result.StdoutTruncated, result.StdoutSize)
+	}
+	if result.StderrTruncated || result.StderrString() != "1\n2\n3\n" {
+		t.Errorf("Stderr within the limit must not be truncated, got %q", result.Stderr)
+	}
+	if result.StdoutFile != "" {
+		t.Errorf("No spill file was requested, got %s", result.StdoutFile)
+	}
+}
+
+func TestCommandOutputSpill(t *testing.T) {
+	dir := t.TempDir()
+	result, err := NewCommand("seq", "1", "10000").
+		WithOutputLimit(OutputLimit{TailBytes: 6, SpillToFile: true, SpillDir: dir}).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+
+	if result.StdoutString() != "10000\n" {
+		t.Errorf("Unexpected retained stdout: %q", result.Stdout)
+	}
+	if !strings.HasPrefix(result.StdoutFile, dir) || !strings.HasPrefix(result.StderrFile, dir) {
+		t.Fatalf("Expected spill files in %s, got %q and %q", dir, result.StdoutFile, result.StderrFile)
+	}
+
+	full, err := os.ReadFile(result.StdoutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(full)) != result.StdoutSize || !bytes.HasPrefix(full, []byte("1\n2\n3\n")) {
+		t.Errorf("Spill file does not contain the complete output (%d of %d bytes)", len(full), result.StdoutSize)
+	}
+}
+
+func TestCommandOutputSpillRemovedOnStartFailure(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewCommand("system-test-missing-binary").
+		WithOutputLimit(OutputLimit{SpillToFile: true, SpillDir: dir}).
+		Run(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error for a missing binary")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Spill files of a command that never ran were not removed: %v", entries)
+	}
+}
+
+func TestSpillFileErrorReported(t *testing.T) {
+	capture, err := newOutputCapture(&OutputLimit{TailBytes: 4, SpillToFile: true, SpillDir: t.TempDir()}, StreamStdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(capture.File())
+
+	// Writing the spill file fails once it is closed underneath the capture
+	capture.(*cappedBuffer).file.Close()
+	capture.Write([]byte("output"))
+
+	result := &CommandResult{}
+	err = result.setOutput(capture, &unlimitedBuffer{})
+	if err == nil || !strings.Contains(err.Error(), "failed to write spill file") {
+		t.Errorf("Expected the spill file error, got: %v", err)
+	}
+	if result.StdoutString() != "tput" {
+		t.Errorf("Expected the output to be kept nonetheless, got %q", result.Stdout)
+	}
+}
@@ -0,0 +1,82 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Returned (wrapped) by AcquirePIDFile if another process holds the lock.
+var ErrAlreadyRunning = errors.New("another instance is already running")
+
+// Describes a PID file locked by another process. It wraps ErrAlreadyRunning.
+//
+// Fields:
+//   - Path: string - the PID file
+//   - PID: int - the pid written by the holder, 0 if it has not written it yet
+type AlreadyRunningError struct {
+	Path string
+	PID  int
+}
+
+func (e *AlreadyRunningError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("%s: locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%s: locked by process %d", e.Path, e.PID)
+}
+
+func (e *AlreadyRunningError) Unwrap() error {
+	return ErrAlreadyRunning
+}
+
+// A PID file locked by the current process, guaranteeing that only one instance of a
+// program runs at a time. The lock is an advisory flock that the kernel releases when
+// the process dies, so a crashed instance never blocks its successor. Only supported on
+// Unix-based systems.
+type PIDFile struct {
+	path     string
+	file     *os.File
+	stalePID int
+}
+
+// Returns the path of the PID file.
+func (p *PIDFile) Path() string {
+	return p.path
+}
+
+// Returns the pid a previous instance left in the file when it died without releasing
+// it, 0 if the file did not exist or was empty.
+func (p *PIDFile) StalePID() int {
+	return p.stalePID
+}
+
+// Reads the pid from a PID file.
+//
+// Parameters:
+//   - path: string - the PID file
+//
+// Returns:
+//   - int: the pid, 0 if the file is empty
+//   - error: if the file cannot be read or does not contain a pid
+func ReadPIDFile(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return parsePID(string(content), path)
+}
+
+func parsePID(content, path string) (int, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return 0, nil
+	}
+	pid, err := strconv.Atoi(content)
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%s does not contain a pid: %q", path, content)
+	}
+	return pid, nil
+}
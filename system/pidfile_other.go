@@ -0,0 +1,17 @@
+//go:build !unix
+
+package system
+
+import (
+	"errors"
+)
+
+var errPIDFileUnsupported = errors.New("PID file locking is only supported on unix")
+
+func AcquirePIDFile(path string) (*PIDFile, error) {
+	return nil, errPIDFileUnsupported
+}
+
+func (p *PIDFile) Release() error {
+	return nil
+}
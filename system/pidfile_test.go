@@ -0,0 +1,112 @@
+//go:build unix
+
+package system
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestAcquirePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.pid")
+
+	pidFile, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+	if pid, err := ReadPIDFile(path); err != nil || pid != os.Getpid() {
+		t.Errorf("Unexpected pid in file. Expected: %d, Got: %d (%v)", os.Getpid(), pid, err)
+	}
+	if pidFile.StalePID() != 0 {
+		t.Errorf("Expected no stale pid for a new file, got %d", pidFile.StalePID())
+	}
+
+	// A second lock on a separate open file fails even within the same process
+	_, err = AcquirePIDFile(path)
+	var running *AlreadyRunningError
+	if !errors.As(err, &running) || !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("Expected an AlreadyRunningError, got: %v", err)
+	}
+	if running.PID != os.Getpid() || running.Path != path {
+		t.Errorf("Unexpected holder: %+v", running)
+	}
+
+	if err := pidFile.Release(); err != nil {
+		t.Errorf("Failed to release PID file: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the PID file to be removed, got: %v", err)
+	}
+
+	pidFile, err = AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("Failed to acquire released PID file: %v", err)
+	}
+	pidFile.Release()
+}
+
+func TestAcquirePIDFileStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.pid")
+	if err := os.WriteFile(path, []byte("4194301\n"), 0644); err != nil {
+		t.Fatalf("Failed to write stale PID file: %v", err)
+	}
+
+	pidFile, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("Failed to reclaim stale PID file: %v", err)
+	}
+	defer pidFile.Release()
+
+	if pidFile.StalePID() != 4194301 {
+		t.Errorf("Unexpected stale pid. Expected: %d, Got: %d", 4194301, pidFile.StalePID())
+	}
+	if pid, _ := ReadPIDFile(path); pid != os.Getpid() {
+		t.Errorf("Expected the file to be rewritten with our pid, got %d", pid)
+	}
+}
+
+func TestAcquirePIDFileReusedPID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.pid")
+	// The pid of the dead instance now belongs to an unrelated process that does not lock the file
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write PID file: %v", err)
+	}
+
+	pidFile, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("Failed to reclaim PID file with a reused pid: %v", err)
+	}
+	defer pidFile.Release()
+
+	if pidFile.StalePID() != os.Getppid() {
+		t.Errorf("Unexpected stale pid. Expected: %d, Got: %d", os.Getppid(), pidFile.StalePID())
+	}
+	if pid, _ := ReadPIDFile(path); pid != os.Getpid() {
+		t.Errorf("Expected the file to be rewritten with our pid, got %d", pid)
+	}
+}
+
+func TestPIDFileReleaseReplaced(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.pid")
+	pidFile, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+
+	// Another instance replaced the file, it must survive our release
+	os.Remove(path)
+	if err := os.WriteFile(path, []byte("1\n"), 0644); err != nil {
+		t.Fatalf("Failed to replace PID file: %v", err)
+	}
+	pidFile.Release()
+
+	if pid, err := ReadPIDFile(path); err != nil || pid != 1 {
+		t.Errorf("Expected the replaced file to be kept, got %d (%v)", pid, err)
+	}
+	if _, err := ReadPIDFile(filepath.Join(t.TempDir(), "missing.pid")); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error for a missing file, got: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+//go:build unix
+
+package system
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// Creates and locks the PID file and writes the pid of the current process into it.
+// A file left behind by an instance that died is reclaimed, its pid is available
+// through StalePID. Only the lock decides whether another instance runs: a pid found in
+// an unlocked file may since have been reused by an unrelated process.
+//
+// Parameters:
+//   - path: string - the PID file, created if it does not exist
+//
+// Returns:
+//   - *PIDFile: the locked file, to be released with Release when the program exits
+//   - error: an *AlreadyRunningError wrapping ErrAlreadyRunning if another process holds
+//     the lock, or an error if the file could not be created or written
+//
+// Example usage:
+//
+//	pidFile, err := AcquirePIDFile("/run/agent.pid")
+//	var running *AlreadyRunningError
+//	if errors.As(err, &running) {
+//	  log.Fatalf("agent already running as pid %d", running.PID)
+//	}
+//	defer pidFile.Release()
+func AcquirePIDFile(path string) (*PIDFile, error) {
+	for attempt := 0; attempt < 10; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PID file: %w", err)
+		}
+
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == syscall.EWOULDBLOCK {
+			content, _ := io.ReadAll(file)
+			file.Close()
+			pid, _ := parsePID(string(content), path)
+			return nil, &AlreadyRunningError{Path: path, PID: pid}
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock PID file: %w", err)
+		}
+
+		// The previous holder may have removed the file between our open and lock,
+		// the lock on the unlinked file would then not exclude anybody
+		if !sameFile(file, path) {
+			file.Close()
+			continue
+		}
+
+		pidFile := &PIDFile{path: path, file: file}
+		content, err := io.ReadAll(file)
+		if err == nil {
+			pidFile.stalePID, _ = parsePID(string(content), path)
+		}
+
+		if err := writePID(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write PID file: %w", err)
+		}
+		return pidFile, nil
+	}
+	return nil, fmt.Errorf("failed to lock PID file: %s keeps being replaced", path)
+}
+
+// Removes the PID file and releases the lock.
+func (p *PIDFile) Release() error {
+	if p.file == nil {
+		return nil
+	}
+
+	var err error
+	// Only remove the file if it is still ours, never one of a successor
+	if sameFile(p.file, p.path) {
+		if removeErr := os.Remove(p.path); removeErr != nil && !os.IsNotExist(removeErr) {
+			err = fmt.Errorf("failed to remove PID file: %w", removeErr)
+		}
+	}
+	p.file.Close()
+	p.file = nil
+	return err
+}
+
+// Returns true if the open file is the one currently found at the path.
+func sameFile(file *os.File, path string) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, current)
+}
+
+func writePID(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,311 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connects several commands through OS pipes, like 'a | b | c' in a shell, without
+// invoking a shell. The standard output of every stage is fed to the standard input of
+// the next one, the standard error of every stage is captured separately. The output
+// limit of a stage applies to its standard error, the one of the last stage also to the
+// captured output of the pipeline.
+//
+// Pipelines always spawn real processes, they do not use the configured Executor.
+//
+// Example usage:
+//
+//	result, err := NewPipeline(
+//	  NewCommand("git", "log", "--format=%an"),
+//	  NewCommand("sort"),
+//	  NewCommand("uniq", "-c"),
+//	).WithDir("repositories/app").Run(ctx)
+type Pipeline struct {
+	stages     []*Command
+	dir        string
+	output     io.Writer
+	outputFile string
+	appendFile bool
+}
+
+// Holds the outcome of a pipeline execution.
+//
+// Fields:
+//   - Stages: []*CommandResult - the result of every stage in order; Stdout is only set for the last stage
+//   - Stdout: []byte - the output of the last stage, empty if it was redirected
+//   - ExitCode: int - the exit code of the last failing stage (pipefail semantics), 0 if all succeeded
+//   - FailedStage: int - the index of the last failing stage, -1 if all succeeded
+//   - StartTime: time.Time - the moment the first stage was started
+//   - EndTime: time.Time - the moment the last stage finished
+//   - Duration: time.Duration - the wall-clock time between StartTime and EndTime
+type PipelineResult struct {
+	Stages      []*CommandResult `json:"stages" bson:"stages" yaml:"stages"`
+	Stdout      []byte           `json:"stdout" bson:"stdout" yaml:"stdout"`
+	ExitCode    int              `json:"exit_code" bson:"exit_code" yaml:"exit_code"`
+	FailedStage int              `json:"failed_stage" bson:"failed_stage" yaml:"failed_stage"`
+	StartTime   time.Time        `json:"start_time" bson:"start_time" yaml:"start_time"`
+	EndTime     time.Time        `json:"end_time" bson:"end_time" yaml:"end_time"`
+	Duration    time.Duration    `json:"duration" bson:"duration" yaml:"duration"`
+}
+
+// Returns true if every stage of the pipeline succeeded.
+func (r *PipelineResult) Success() bool {
+	return r.FailedStage < 0
+}
+
+// Creates a pipeline of the given commands. The standard input of the first command
+// is used as input of the pipeline.
+//
+// Parameters:
+//   - stages: ...*Command - the commands to connect, in order
+//
+// Returns:
+//   - *Pipeline: the configured pipeline
+func NewPipeline(stages ...*Command) *Pipeline {
+	return &Pipeline{stages: append([]*Command(nil), stages...)}
+}
+
+// Appends a further stage to the pipeline.
+func (p *Pipeline) Pipe(cmd *Command) *Pipeline {
+	p.stages = append(p.stages, cmd)
+	return p
+}
+
+// Sets the working directory of every stage that has none of its own.
+func (p *Pipeline) WithDir(dir string) *Pipeline {
+	p.dir = dir
+	return p
+}
+
+// Writes the output of the last stage to the writer instead of capturing it.
+func (p *Pipeline) WithOutput(w io.Writer) *Pipeline {
+	p.output = w
+	return p
+}
+
+// Writes the output of the last stage to the file, truncating it, instead of capturing it.
+func (p *Pipeline) WithOutputFile(path string) *Pipeline {
+	p.outputFile = path
+	p.appendFile = false
+	return p
+}
+
+// Appends the output of the last stage to the file instead of capturing it.
+func (p *Pipeline) WithAppendFile(path string) *Pipeline {
+	p.outputFile = path
+	p.appendFile = true
+	return p
+}
+
+// Returns the pipeline in shell notation.
+func (p *Pipeline) String() string {
+	parts := make([]string, 0, len(p.stages))
+	for _, stage := range p.stages {
+		parts = append(parts, stage.String())
+	}
+
+	s := strings.Join(parts, " | ")
+	if p.outputFile != "" {
+		redirect := " > "
+		if p.appendFile {
+			redirect = " >> "
+		}
+		s += redirect + quoteArg(p.outputFile)
+	}
+	return s
+}
+
+// Runs all stages concurrently and waits for every one of them to finish.
+// Like a shell with 'set -o pipefail', the pipeline fails if any stage fails, and the
+// exit code of the last failing stage is reported. A stage writing into a pipe whose
+// reader has already exited is killed by SIGPIPE and counts as failed, too.
+// Once the context ends, the process groups of all stages are killed.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of the pipeline
+//
+// Returns:
+//   - *PipelineResult: the outcome of every stage, nil if the pipeline could not be started
+//   - error: an error if a stage could not be started, a stage failed or the context ended
+func (p *Pipeline) Run(ctx context.Context) (*PipelineResult, error) {
+	if len(p.stages) == 0 {
+		return nil, fmt.Errorf("pipeline has no stages")
+	}
+
+	var output io.Writer
+	var stdout outputCapture
+	switch {
+	case p.outputFile != "":
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if p.appendFile {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		file, err := os.OpenFile(p.outputFile, flags, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open pipeline output: %w", err)
+		}
+		defer file.Close()
+		output = file
+	case p.output != nil:
+		output = p.output
+	default:
+		// The output limit of the last stage applies to the captured output
+		capture, err := newOutputCapture(p.stages[len(p.stages)-1].outputLimit, StreamStdout)
+		if err != nil {
+			return nil, err
+		}
+		stdout = capture
+		output = capture
+	}
+
+	cmds := make([]*exec.Cmd, len(p.stages))
+	resources := make([]*resourceControl, len(p.stages))
+	stderrs := make([]outputCapture, 0, len(p.stages))
+	var pipes []*os.File
+	closePipes := func() {
+		for _, pipe := range pipes {
+			pipe.Close()
+		}
+	}
+	discardCaptures := func() {
+		for _, stderr := range stderrs {
+			discardCapture(stderr)
+		}
+		if stdout != nil {
+			discardCapture(stdout)
+		}
+		for _, rc := range resources {
+			rc.release()
+		}
+	}
+	cleanup := func() {
+		closePipes()
+		discardCaptures()
+	}
+
+	for i, stage := range p.stages {
+		stderr, err := newOutputCapture(stage.outputLimit, StreamStderr)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("pipeline stage %d (%s): %w", i, stage, err)
+		}
+		stderrs = append(stderrs, stderr)
+
+		cmds[i] = stage.build(ctx)
+		if cmds[i].Dir == "" {
+			cmds[i].Dir = p.dir
+		}
+		cmds[i].Stderr = stderr
+		cmds[i].WaitDelay = commandWaitDelay
+		configureProcessGroup(cmds[i])
+		if err := applyCredentials(stage, cmds[i]); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("pipeline stage %d (%s): %w", i, stage, err)
+		}
+		rc, err := prepareResources(stage, cmds[i])
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("pipeline stage %d (%s): %w", i, stage, err)
+		}
+		resources[i] = rc
+
+		if i > 0 {
+			reader, writer, err := os.Pipe()
+			if err != nil {
+				cleanup()
+				return nil, fmt.Errorf("failed to create pipe: %w", err)
+			}
+			pipes = append(pipes, reader, writer)
+			cmds[i-1].Stdout = writer
+			cmds[i].Stdin = reader
+		}
+	}
+	cmds[len(cmds)-1].Stdout = output
+
+	result := &PipelineResult{FailedStage: -1, StartTime: time.Now()}
+
+	for i, cmd := range cmds {
+		if err := startCommand(p.stages[i], cmd, resources[i]); err != nil {
+			closePipes()
+			for _, started := range cmds[:i] {
+				started.Cancel()
+				started.Wait()
+			}
+			discardCaptures()
+			return nil, fmt.Errorf("failed to start pipeline stage %d (%s): %w", i, p.stages[i], err)
+		}
+	}
+
+	// The stages hold their own copies of the pipe ends now. Closing ours makes sure
+	// every reader sees EOF once its writer exits.
+	closePipes()
+
+	stages := make([]*CommandResult, len(cmds))
+	waitErrs := make([]error, len(cmds))
+	outputErrs := make([]error, len(cmds))
+	var wg sync.WaitGroup
+	for i, cmd := range cmds {
+		wg.Add(1)
+		go func(i int, cmd *exec.Cmd) {
+			defer wg.Done()
+			waitErrs[i] = cmd.Wait()
+
+			stage := &CommandResult{
+				Command:   p.stages[i].String(),
+				ExitCode:  -1,
+				StartTime: result.StartTime,
+				EndTime:   time.Now(),
+			}
+			stage.Duration = stage.EndTime.Sub(stage.StartTime)
+			outputErrs[i] = stage.setStderr(stderrs[i])
+			stage.setExitStatus(cmd.ProcessState)
+			resources[i].finish(stage)
+			stages[i] = stage
+		}(i, cmd)
+	}
+	wg.Wait()
+	result.Stages = stages
+
+	var failure error
+	for i, err := range waitErrs {
+		if err != nil {
+			result.FailedStage = i
+			result.ExitCode = stages[i].ExitCode
+			failure = fmt.Errorf("stage %d (%s): %w", i, p.stages[i], err)
+		}
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	if stdout != nil {
+		last := len(result.Stages) - 1
+		if err := result.Stages[last].setStdout(stdout); err != nil {
+			outputErrs[last] = err
+		}
+		result.Stdout = result.Stages[last].Stdout
+	}
+
+	var outputErr error
+	for i, err := range outputErrs {
+		if err != nil {
+			outputErr = fmt.Errorf("stage %d (%s): %w", i, p.stages[i], err)
+			break
+		}
+	}
+
+	if failure != nil {
+		err := fmt.Errorf("pipeline failed: %s: %w", p, contextError(ctx, &CommandResult{Duration: result.Duration}, failure))
+		if outputErr != nil {
+			err = errors.Join(err, outputErr)
+		}
+		return result, err
+	}
+	return result, outputErr
+}
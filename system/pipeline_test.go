@@ -0,0 +1,118 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	result, err := NewPipeline(
+		NewCommand("printf", "b\na\nc\na\n"),
+		NewCommand("sort"),
+		NewCommand("uniq", "-c"),
+	).Pipe(NewCommand("wc", "-l")).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run pipeline: %v", err)
+	}
+
+	if string(bytes.TrimSpace(result.Stdout)) != "3" {
+		t.Errorf("Unexpected pipeline output: %q", result.Stdout)
+	}
+	if len(result.Stages) != 4 || !result.Success() || result.ExitCode != 0 {
+		t.Errorf("Unexpected pipeline result: %+v", result)
+	}
+}
+
+func TestPipelineFail(t *testing.T) {
+	result, err := NewPipeline(
+		NewCommand("sh", "-c", "echo broken >&2; exit 4"),
+		NewCommand("cat"),
+	).Run(context.Background())
+	if err == nil {
+		t.Fatal("Expected the pipeline to fail like with pipefail")
+	}
+
+	if result.FailedStage != 0 || result.ExitCode != 4 {
+		t.Errorf("Expected stage 0 to fail with code 4, got stage %d with code %d", result.FailedStage, result.ExitCode)
+	}
+	if result.Stages[0].StderrString() != "broken\n" {
+		t.Errorf("Unexpected stderr of stage 0: %q", result.Stages[0].Stderr)
+	}
+	if !result.Stages[1].Success() {
+		t.Errorf("Stage 1 should have succeeded: %+v", result.Stages[1])
+	}
+}
+
+func TestPipelineOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+
+	for i := 0; i < 2; i++ {
+		_, err := NewPipeline(NewCommand("echo", "line"), NewCommand("tr", "a-z", "A-Z")).
+			WithAppendFile(path).
+			Run(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to run pipeline: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "LINE\nLINE\n" {
+		t.Errorf("Unexpected file content: %q", content)
+	}
+
+	var buf bytes.Buffer
+	result, err := NewPipeline(NewCommand("echo", "to writer")).WithOutput(&buf).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run pipeline: %v", err)
+	}
+	if buf.String() != "to writer\n" || len(result.Stdout) != 0 {
+		t.Errorf("Output was not redirected, writer got %q, result %q", buf.String(), result.Stdout)
+	}
+}
+
+func TestPipelineTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewPipeline(NewCommand("sleep", "30"), NewCommand("cat")).Run(ctx)
+	if !errors.Is(err, ErrCommandTimeout) {
+		t.Errorf("Expected ErrCommandTimeout, got: %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("Pipeline was not killed in time")
+	}
+}
+
+func TestPipelineStartFailure(t *testing.T) {
+	_, err := NewPipeline(NewCommand("sleep", "30"), NewCommand("system-test-missing-binary")).Run(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error for a missing stage binary")
+	}
+}
+
+func TestPipelineOutputLimit(t *testing.T) {
+	result, err := NewPipeline(
+		NewCommand("sh", "-c", "seq 1000; seq 1000 >&2").WithOutputLimit(OutputLimit{HeadBytes: 2, TailBytes: 5}),
+		NewCommand("cat").WithOutputLimit(OutputLimit{TailBytes: 4}),
+	).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run pipeline: %v", err)
+	}
+
+	first, last := result.Stages[0], result.Stages[1]
+	if string(first.Stderr) != "1\n1000\n" || !first.StderrTruncated || first.StderrSize != 3893 {
+		t.Errorf("Expected the stderr of the first stage to be capped, got %q (%d bytes)", first.Stderr, first.StderrSize)
+	}
+	if string(result.Stdout) != "000\n" || !last.StdoutTruncated || last.StdoutSize != 3893 {
+		t.Errorf("Expected the output of the last stage to be capped, got %q (%d bytes)", result.Stdout, last.StdoutSize)
+	}
+}
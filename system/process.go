@@ -0,0 +1,446 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The unit of the CPU times and start times in /proc/<pid>/stat, USER_HZ, which is
+// 100 on all architectures Linux runs on today.
+const clockTicksPerSecond = 100
+
+// Enumerates the processes of the host from /proc, without running ps. Only meaningful on Linux.
+//
+// All files are read below Root, so the table can be pointed at a copy of /proc and
+// /etc/passwd, e.g. a fixture tree in tests or the host filesystem mounted into a container.
+//
+// Fields:
+//   - Root: string - the directory containing proc and etc, "/" if empty
+//
+// Example usage:
+//
+//	workers, err := ProcessTable{}.Find(ProcessFilter{Name: "worker", Ancestor: os.Getpid()})
+//	for _, worker := range workers {
+//	  fmt.Printf("%d %s %d bytes\n", worker.PID, worker.User, worker.RSS)
+//	}
+type ProcessTable struct {
+	Root string
+}
+
+// Describes a running process.
+//
+// Fields:
+//   - PID: int - the process id
+//   - PPID: int - the id of the parent process, 0 for the init process and kernel threads
+//   - PGID: int - the id of the process group
+//   - Name: string - the command name of the process, truncated to 15 characters by the kernel
+//   - Cmdline: []string - the command line, empty for kernel threads and zombies
+//   - Exe: string - the path of the executable, empty if it cannot be read
+//   - State: string - the state, e.g. "R" running, "S" sleeping, "D" disk sleep, "Z" zombie
+//   - UID: int - the real user id
+//   - User: string - the name of the real user, the uid if the name is unknown
+//   - StartTime: time.Time - the moment the process was started
+//   - RSS: int64 - the resident set size in bytes
+//   - UserTime: time.Duration - the CPU time spent in user mode
+//   - SystemTime: time.Duration - the CPU time spent in kernel mode
+//   - Threads: int - the number of threads
+type ProcessInfo struct {
+	PID        int           `json:"pid" bson:"pid" yaml:"pid"`
+	PPID       int           `json:"ppid" bson:"ppid" yaml:"ppid"`
+	PGID       int           `json:"pgid" bson:"pgid" yaml:"pgid"`
+	Name       string        `json:"name" bson:"name" yaml:"name"`
+	Cmdline    []string      `json:"cmdline" bson:"cmdline" yaml:"cmdline"`
+	Exe        string        `json:"exe" bson:"exe" yaml:"exe"`
+	State      string        `json:"state" bson:"state" yaml:"state"`
+	UID        int           `json:"uid" bson:"uid" yaml:"uid"`
+	User       string        `json:"user" bson:"user" yaml:"user"`
+	StartTime  time.Time     `json:"start_time" bson:"start_time" yaml:"start_time"`
+	RSS        int64         `json:"rss" bson:"rss" yaml:"rss"`
+	UserTime   time.Duration `json:"user_time" bson:"user_time" yaml:"user_time"`
+	SystemTime time.Duration `json:"system_time" bson:"system_time" yaml:"system_time"`
+	Threads    int           `json:"threads" bson:"threads" yaml:"threads"`
+}
+
+// Selects processes in ProcessTable.Find. Empty fields match every process, a process
+// has to match all fields that are set.
+//
+// Fields:
+//   - Name: string - the exact command name, or the base name of the executable or of the first argument
+//   - Pattern: string - a regular expression matched against the command line joined by spaces,
+//     or the command name for processes without one
+//   - User: string - the user name or uid the process runs as
+//   - Ancestor: int - only processes below this pid in the process tree, 0 for no restriction
+type ProcessFilter struct {
+	Name     string
+	Pattern  string
+	User     string
+	Ancestor int
+}
+
+// A process with its child processes, as built by BuildProcessTree.
+//
+// Fields:
+//   - Process: ProcessInfo - the process
+//   - Children: []*ProcessNode - the child processes, ordered by pid
+type ProcessNode struct {
+	Process  ProcessInfo    `json:"process" bson:"process" yaml:"process"`
+	Children []*ProcessNode `json:"children" bson:"children" yaml:"children"`
+}
+
+// Returns the command line joined by spaces, or the command name in brackets like ps
+// does for processes without one.
+func (p *ProcessInfo) CommandLine() string {
+	if len(p.Cmdline) == 0 {
+		return "[" + p.Name + "]"
+	}
+	return strings.Join(p.Cmdline, " ")
+}
+
+// Lists all processes, ordered by pid. Processes exiting while the table is read are skipped.
+//
+// Returns:
+//   - []ProcessInfo: the processes
+//   - error: if the process directory or the boot time could not be read
+func (t ProcessTable) List() ([]ProcessInfo, error) {
+	entries, err := os.ReadDir(rootPath(t.Root, "proc"))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := t.newReader()
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []ProcessInfo
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		process, err := reader.read(pid)
+		if errors.Is(err, fs.ErrNotExist) {
+			// The process exited after the directory was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		processes = append(processes, *process)
+	}
+
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
+	return processes, nil
+}
+
+// Returns the process with the given pid.
+//
+// Returns:
+//   - *ProcessInfo: the process
+//   - error: wrapping fs.ErrNotExist if there is no such process
+func (t ProcessTable) Get(pid int) (*ProcessInfo, error) {
+	reader, err := t.newReader()
+	if err != nil {
+		return nil, err
+	}
+
+	process, err := reader.read(pid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read process %d: %w", pid, err)
+	}
+	return process, nil
+}
+
+// Returns the processes matching the filter, ordered by pid.
+//
+// Parameters:
+//   - filter: ProcessFilter - the conditions the processes have to match
+//
+// Returns:
+//   - []ProcessInfo: the matching processes
+//   - error: if the pattern does not compile or the processes could not be listed
+func (t ProcessTable) Find(filter ProcessFilter) ([]ProcessInfo, error) {
+	var pattern *regexp.Regexp
+	if filter.Pattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(filter.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid process pattern: %w", err)
+		}
+	}
+
+	processes, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var descendants map[int]bool
+	if filter.Ancestor > 0 {
+		descendants = descendantsOf(processes, filter.Ancestor)
+	}
+
+	var matches []ProcessInfo
+	for _, process := range processes {
+		if filter.Name != "" && !process.hasName(filter.Name) {
+			continue
+		}
+		if pattern != nil {
+			line := strings.Join(process.Cmdline, " ")
+			if line == "" {
+				line = process.Name
+			}
+			if !pattern.MatchString(line) {
+				continue
+			}
+		}
+		if filter.User != "" && filter.User != process.User && filter.User != strconv.Itoa(process.UID) {
+			continue
+		}
+		if descendants != nil && !descendants[process.PID] {
+			continue
+		}
+		matches = append(matches, process)
+	}
+	return matches, nil
+}
+
+// Arranges the processes in trees following their parent pids. Processes whose parent
+// is not part of the list become roots.
+//
+// Parameters:
+//   - processes: []ProcessInfo - the processes, e.g. from ProcessTable.List
+//
+// Returns:
+//   - []*ProcessNode: the roots of the trees, ordered by pid
+//
+// Example usage:
+//
+//	processes, _ := ProcessTable{}.List()
+//	for _, root := range BuildProcessTree(processes) {
+//	  fmt.Println(root.Process.PID, root.Process.CommandLine())
+//	}
+func BuildProcessTree(processes []ProcessInfo) []*ProcessNode {
+	nodes := make(map[int]*ProcessNode, len(processes))
+	for _, process := range processes {
+		nodes[process.PID] = &ProcessNode{Process: process}
+	}
+
+	var roots []*ProcessNode
+	for _, process := range processes {
+		node := nodes[process.PID]
+		parent, ok := nodes[process.PPID]
+		if ok && process.PPID != process.PID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	byPID := func(nodes []*ProcessNode) {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Process.PID < nodes[j].Process.PID })
+	}
+	byPID(roots)
+	for _, node := range nodes {
+		byPID(node.Children)
+	}
+	return roots
+}
+
+// Returns true if the name is the command name of the process, or the base name of its
+// executable or its first argument.
+func (p *ProcessInfo) hasName(name string) bool {
+	if p.Name == name || (p.Exe != "" && filepath.Base(p.Exe) == name) {
+		return true
+	}
+	return len(p.Cmdline) > 0 && filepath.Base(p.Cmdline[0]) == name
+}
+
+// Returns the pids of all processes below the ancestor in the process tree.
+func descendantsOf(processes []ProcessInfo, ancestor int) map[int]bool {
+	children := make(map[int][]int)
+	for _, process := range processes {
+		children[process.PPID] = append(children[process.PPID], process.PID)
+	}
+
+	descendants := make(map[int]bool)
+	pending := []int{ancestor}
+	for len(pending) > 0 {
+		pid := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, child := range children[pid] {
+			if !descendants[child] && child != ancestor {
+				descendants[child] = true
+				pending = append(pending, child)
+			}
+		}
+	}
+	return descendants
+}
+
+// Reads processes, sharing the boot time and user names between them.
+type processReader struct {
+	table    ProcessTable
+	bootTime time.Time
+	pageSize int64
+	users    map[int]string
+}
+
+func (t ProcessTable) newReader() (*processReader, error) {
+	bootTime, err := HostInfoCollector{Root: t.Root}.BootTime()
+	if err != nil {
+		return nil, err
+	}
+
+	return &processReader{
+		table:    t,
+		bootTime: bootTime,
+		pageSize: int64(os.Getpagesize()),
+		users:    readPasswd(rootPath(t.Root, "etc/passwd")),
+	}, nil
+}
+
+// Reads a single process from /proc/<pid>.
+func (r *processReader) read(pid int) (*ProcessInfo, error) {
+	dir := rootPath(r.table.Root, filepath.Join("proc", strconv.Itoa(pid)))
+
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, processReadError(err)
+	}
+	if len(stat) == 0 {
+		return nil, fmt.Errorf("process %d exited: %w", pid, fs.ErrNotExist)
+	}
+	process, started, err := parseProcessStat(string(stat))
+	if err != nil {
+		return nil, err
+	}
+	process.StartTime = r.bootTime.Add(started)
+	process.RSS *= r.pageSize
+
+	status, err := os.ReadFile(filepath.Join(dir, "status"))
+	if err != nil {
+		return nil, processReadError(err)
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if value, ok := strings.CutPrefix(line, "Uid:"); ok {
+			if fields := strings.Fields(value); len(fields) > 0 {
+				process.UID, _ = strconv.Atoi(fields[0])
+			}
+			break
+		}
+	}
+	process.User = r.userName(process.UID)
+
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		cmdline = []byte(strings.TrimRight(string(cmdline), "\x00"))
+		if len(cmdline) > 0 {
+			process.Cmdline = strings.Split(string(cmdline), "\x00")
+		}
+	}
+	// Reading the executable of processes of other users requires privileges
+	process.Exe, _ = os.Readlink(filepath.Join(dir, "exe"))
+
+	return process, nil
+}
+
+// Reports the files of a process that exited while it was read as not existing. Depending
+// on the moment, reading them fails with ENOENT or ESRCH.
+func processReadError(err error) error {
+	if errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+	}
+	return err
+}
+
+// Returns the name of the user, looking it up in the system user database for users
+// missing from the passwd file of the root.
+func (r *processReader) userName(uid int) string {
+	if name, ok := r.users[uid]; ok {
+		return name
+	}
+
+	name := strconv.Itoa(uid)
+	if r.table.Root == "" || r.table.Root == "/" {
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+	}
+	r.users[uid] = name
+	return name
+}
+
+// Parses the content of /proc/<pid>/stat. Returns the RSS in pages, and the time the
+// process was started after boot.
+func parseProcessStat(stat string) (*ProcessInfo, time.Duration, error) {
+	// The command name is in parentheses and may itself contain spaces and parentheses
+	start := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return nil, 0, fmt.Errorf("unexpected format of process stat: %q", stat)
+	}
+
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return nil, 0, fmt.Errorf("unexpected format of process stat: %q", stat)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(stat[:start]))
+	if err != nil {
+		return nil, 0, fmt.Errorf("unexpected format of process stat: %w", err)
+	}
+
+	number := func(index int) int64 {
+		value, _ := strconv.ParseInt(fields[index], 10, 64)
+		return value
+	}
+	ticks := func(index int) time.Duration {
+		return time.Duration(number(index)) * time.Second / clockTicksPerSecond
+	}
+
+	// The indexes are the field numbers of proc(5) minus 3
+	return &ProcessInfo{
+		PID:        pid,
+		Name:       stat[start+1 : end],
+		State:      fields[0],
+		PPID:       int(number(1)),
+		PGID:       int(number(2)),
+		UserTime:   ticks(11),
+		SystemTime: ticks(12),
+		Threads:    int(number(17)),
+		RSS:        number(21),
+	}, ticks(19), nil
+}
+
+// Reads the user names of a passwd file by uid. Returns an empty map if the file cannot be read.
+func readPasswd(path string) map[int]string {
+	users := make(map[int]string)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return users
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		if _, exists := users[uid]; !exists {
+			users[uid] = fields[0]
+		}
+	}
+	return users
+}
@@ -0,0 +1,175 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Writes the /proc files of a process below root.
+func writeProcessFixture(t *testing.T, root string, pid, ppid, uid int, name, state string, cmdline ...string) {
+	t.Helper()
+
+	dir := fmt.Sprintf("proc/%d/", pid)
+	stat := fmt.Sprintf("%d (%s) %s %d %d %d 0 -1 4194560 100 0 0 0 250 50 0 0 20 0 3 0 1500 10000000 256 18446744073709551615\n",
+		pid, name, state, ppid, pid, pid)
+	status := fmt.Sprintf("Name:\t%s\nState:\t%s\nUid:\t%d\t%d\t%d\t%d\n", name, state, uid, uid, uid, uid)
+
+	cmd := ""
+	for _, arg := range cmdline {
+		cmd += arg + "\x00"
+	}
+	writeFixture(t, root, map[string]string{dir + "stat": stat, dir + "status": status, dir + "cmdline": cmd})
+
+	if len(cmdline) > 0 {
+		if err := os.Symlink(cmdline[0], filepath.Join(root, dir, "exe")); err != nil {
+			t.Fatalf("Failed to create exe link: %v", err)
+		}
+	}
+}
+
+func newProcessFixture(t *testing.T) string {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"proc/stat":  "cpu  1 2 3 4\nbtime 1697520000\n",
+		"proc/self":  "",
+		"etc/passwd": "root:x:0:0:root:/root:/bin/bash\ndeploy:x:1000:1000::/home/deploy:/bin/sh\n",
+	})
+
+	writeProcessFixture(t, root, 1, 0, 0, "systemd", "S", "/sbin/init", "splash")
+	writeProcessFixture(t, root, 2, 0, 0, "kthreadd", "S")
+	writeProcessFixture(t, root, 100, 1, 1000, "supervisor", "S", "/usr/bin/supervisor", "--config", "/etc/app.yaml")
+	writeProcessFixture(t, root, 101, 100, 1000, "worker", "R", "/usr/bin/worker", "--queue", "emails")
+	writeProcessFixture(t, root, 102, 100, 1000, "worker", "S", "/usr/bin/worker", "--queue", "reports")
+	writeProcessFixture(t, root, 103, 101, 1000, "sh (helper)", "Z")
+	writeProcessFixture(t, root, 200, 1, 0, "worker", "S", "/opt/other/worker")
+	writeProcessFixture(t, root, 300, 1, 4242, "cron", "S", "/usr/sbin/cron", "-f")
+	return root
+}
+
+func TestProcessTableList(t *testing.T) {
+	root := newProcessFixture(t)
+	processes, err := ProcessTable{Root: root}.List()
+	if err != nil {
+		t.Fatalf("Failed to list processes: %v", err)
+	}
+	if len(processes) != 8 {
+		t.Fatalf("Unexpected number of processes. Expected: %d, Got: %d", 8, len(processes))
+	}
+
+	worker := processes[3]
+	if worker.PID != 101 || worker.PPID != 100 || worker.Name != "worker" || worker.State != "R" ||
+		worker.User != "deploy" || worker.UID != 1000 || worker.PGID != 101 || worker.Exe != "/usr/bin/worker" || worker.Threads != 3 {
+		t.Errorf("Unexpected process: %+v", worker)
+	}
+	if worker.CommandLine() != "/usr/bin/worker --queue emails" {
+		t.Errorf("Unexpected command line: %s", worker.CommandLine())
+	}
+	if worker.UserTime != 2500*time.Millisecond || worker.SystemTime != 500*time.Millisecond {
+		t.Errorf("Unexpected CPU times: %v and %v", worker.UserTime, worker.SystemTime)
+	}
+	if !worker.StartTime.Equal(time.Unix(1697520015, 0)) {
+		t.Errorf("Unexpected start time: %v", worker.StartTime)
+	}
+	if worker.RSS != 256*int64(os.Getpagesize()) {
+		t.Errorf("Unexpected RSS: %d", worker.RSS)
+	}
+
+	helper := processes[5]
+	if helper.Name != "sh (helper)" || helper.State != "Z" || helper.CommandLine() != "[sh (helper)]" {
+		t.Errorf("Unexpected zombie process: %+v", helper)
+	}
+	if processes[7].User != "4242" {
+		t.Errorf("Expected the uid as user name of an unknown user, got %s", processes[7].User)
+	}
+
+	// Processes exiting while the table is read are skipped
+	writeFixture(t, root, map[string]string{"proc/400/stat": "", "proc/401/cmdline": ""})
+	if processes, err := (ProcessTable{Root: root}).List(); err != nil || len(processes) != 8 {
+		t.Errorf("Expected vanished processes to be skipped, got %d processes (%v)", len(processes), err)
+	}
+	if err := processReadError(&fs.PathError{Op: "read", Path: "/proc/400/status", Err: syscall.ESRCH}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected ESRCH to be reported as a missing process, got: %v", err)
+	}
+}
+
+func TestProcessTableFind(t *testing.T) {
+	table := ProcessTable{Root: newProcessFixture(t)}
+
+	tests := []struct {
+		filter   ProcessFilter
+		expected []int
+	}{
+		{filter: ProcessFilter{Name: "worker"}, expected: []int{101, 102, 200}},
+		{filter: ProcessFilter{Name: "worker", User: "deploy"}, expected: []int{101, 102}},
+		{filter: ProcessFilter{Pattern: `--queue (emails|billing)`}, expected: []int{101}},
+		{filter: ProcessFilter{Pattern: `^\[?kthread`}, expected: []int{2}},
+		{filter: ProcessFilter{Ancestor: 100}, expected: []int{101, 102, 103}},
+		{filter: ProcessFilter{User: "0"}, expected: []int{1, 2, 200}},
+	}
+
+	for _, test := range tests {
+		processes, err := table.Find(test.filter)
+		if err != nil {
+			t.Errorf("Failed to find processes for %+v: %v", test.filter, err)
+			continue
+		}
+		var pids []int
+		for _, process := range processes {
+			pids = append(pids, process.PID)
+		}
+		if fmt.Sprint(pids) != fmt.Sprint(test.expected) {
+			t.Errorf("Unexpected processes for %+v. Expected: %v, Got: %v", test.filter, test.expected, pids)
+		}
+	}
+
+	if _, err := table.Find(ProcessFilter{Pattern: "("}); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+	if _, err := table.Get(999); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist for a missing process, got: %v", err)
+	}
+}
+
+func TestBuildProcessTree(t *testing.T) {
+	processes, err := ProcessTable{Root: newProcessFixture(t)}.List()
+	if err != nil {
+		t.Fatalf("Failed to list processes: %v", err)
+	}
+
+	roots := BuildProcessTree(processes)
+	if len(roots) != 2 || roots[0].Process.PID != 1 || roots[1].Process.PID != 2 {
+		t.Fatalf("Unexpected roots: %+v", roots)
+	}
+
+	var children []int
+	for _, child := range roots[0].Children {
+		children = append(children, child.Process.PID)
+	}
+	if fmt.Sprint(children) != "[100 200 300]" {
+		t.Errorf("Unexpected children of init: %v", children)
+	}
+	supervisor := roots[0].Children[0]
+	if len(supervisor.Children) != 2 || len(supervisor.Children[0].Children) != 1 {
+		t.Errorf("Unexpected subtree of the supervisor: %+v", supervisor)
+	}
+}
+
+func TestProcessTableSelf(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("No /proc filesystem")
+	}
+
+	self, err := ProcessTable{}.Get(os.Getpid())
+	if err != nil {
+		t.Fatalf("Failed to read the current process: %v", err)
+	}
+	if self.PPID != os.Getppid() || self.RSS <= 0 || time.Since(self.StartTime) < 0 || time.Since(self.StartTime) > time.Hour {
+		t.Errorf("Unexpected current process: %+v", self)
+	}
+}
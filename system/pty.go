@@ -0,0 +1,320 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Returned (wrapped) by PTYSession.Expect if the pattern did not appear in time.
+var ErrExpectTimeout = errors.New("expected output did not appear")
+
+// The timeout of an ExpectStep that does not set one.
+const DefaultExpectTimeout = 30 * time.Second
+
+// Describes one step of a scripted interactive session: wait for the pattern to
+// appear in the terminal output, then type the response.
+//
+// Fields:
+//   - Pattern: string - the regular expression to wait for
+//   - Send: string - the input typed once the pattern appeared, include "\n" to press enter
+//   - Timeout: time.Duration - how long to wait for the pattern, DefaultExpectTimeout if 0
+type ExpectStep struct {
+	Pattern string
+	Send    string
+	Timeout time.Duration
+}
+
+// A command running attached to a pseudo-terminal. The command sees a real terminal on
+// its standard input, output and error, so it behaves as if run interactively.
+//
+// The terminal output is collected in a transcript exactly as a terminal would receive it,
+// including colors, carriage returns and the echo of the typed input.
+type PTYSession struct {
+	cmd       *exec.Cmd
+	ctx       context.Context
+	command   string
+	pty       *os.File
+	start     time.Time
+	resources *resourceControl
+
+	mu         sync.Mutex
+	state      *os.ProcessState
+	pending    []byte
+	transcript []byte
+	changed    chan struct{}
+	readDone   chan struct{}
+}
+
+// Starts the command attached to a new pseudo-terminal of 24 rows and 80 columns.
+// Line handlers and output limits of the command are not used, the output is available
+// through Expect and the transcript. Only supported on Linux.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of the command
+//
+// Returns:
+//   - *PTYSession: the running session, which has to be finished with Wait or Close
+//   - error: if the terminal could not be created or the command could not be started
+//
+// Example usage:
+//
+//	session, err := NewCommand("ssh-keygen", "-f", keyFile).StartPTY(ctx)
+//	if err != nil {
+//	  return err
+//	}
+//	defer session.Close()
+//	session.Expect(`passphrase`, 10*time.Second)
+//	session.SendLine("")
+//	result, err := session.Wait()
+func (c *Command) StartPTY(ctx context.Context) (*PTYSession, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+
+	if err := setPTYSize(master, 24, 80); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("failed to set terminal size: %w", err)
+	}
+
+	cmd := c.build(ctx)
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	configurePTYProcess(cmd)
+	if err := applyCredentials(c, cmd); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+	resources, err := prepareResources(c, cmd)
+	if err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+
+	s := &PTYSession{
+		cmd:       cmd,
+		ctx:       ctx,
+		command:   c.String(),
+		pty:       master,
+		resources: resources,
+		changed:   make(chan struct{}),
+		readDone:  make(chan struct{}),
+	}
+
+	s.start = time.Now()
+	err = startCommand(c, cmd, resources)
+	// The child holds its own copy of the terminal now
+	slave.Close()
+	if err != nil {
+		resources.release()
+		master.Close()
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	go s.read()
+	return s, nil
+}
+
+// Runs the command attached to a pseudo-terminal and answers its prompts following the
+// script. The result holds the complete terminal transcript as stdout.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of the command
+//   - steps: ...ExpectStep - the prompts to wait for and the responses to type, in order
+//
+// Returns:
+//   - *CommandResult: the outcome of the execution, nil if the command could not be started
+//   - error: if a prompt did not appear in time, or the command did not exit successfully
+//
+// Example usage:
+//
+//	result, err := NewCommand("adduser", "deploy").RunInteractive(ctx,
+//	  ExpectStep{Pattern: `New password:`, Send: password + "\n"},
+//	  ExpectStep{Pattern: `Retype new password:`, Send: password + "\n"},
+//	)
+func (c *Command) RunInteractive(ctx context.Context, steps ...ExpectStep) (*CommandResult, error) {
+	session, err := c.StartPTY(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, step := range steps {
+		timeout := step.Timeout
+		if timeout <= 0 {
+			timeout = DefaultExpectTimeout
+		}
+
+		if _, err := session.Expect(step.Pattern, timeout); err != nil {
+			session.Close()
+			result, _ := session.Wait()
+			return result, fmt.Errorf("interactive step %d of %s: %w", i+1, session.command, err)
+		}
+		if err := session.Send(step.Send); err != nil {
+			session.Close()
+			result, _ := session.Wait()
+			return result, fmt.Errorf("interactive step %d of %s: %w", i+1, session.command, err)
+		}
+	}
+
+	result, err := session.Wait()
+	if err != nil && result != nil {
+		return result, fmt.Errorf("command failed: %s: %w", session.command, err)
+	}
+	return result, err
+}
+
+// Waits until the regular expression matches the terminal output received since the
+// previous match, and returns that output up to the end of the match.
+//
+// Parameters:
+//   - pattern: string - the regular expression to wait for
+//   - timeout: time.Duration - how long to wait
+//
+// Returns:
+//   - string: the output up to and including the match
+//   - error: ErrExpectTimeout if the pattern did not appear in time, io.EOF if the
+//     command closed the terminal first, or the error of the context
+func (s *PTYSession) Expect(pattern string, timeout time.Duration) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid expect pattern: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		if loc := re.FindIndex(s.pending); loc != nil {
+			out := string(s.pending[:loc[1]])
+			s.pending = s.pending[loc[1]:]
+			s.mu.Unlock()
+			return out, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-s.readDone:
+			s.mu.Lock()
+			matched := re.Match(s.pending)
+			s.mu.Unlock()
+			if !matched {
+				return "", fmt.Errorf("waiting for %q: %w", pattern, io.EOF)
+			}
+		case <-timer.C:
+			return "", fmt.Errorf("%w within %s: %q", ErrExpectTimeout, timeout, pattern)
+		case <-s.ctx.Done():
+			return "", s.ctx.Err()
+		}
+	}
+}
+
+// Types the input into the terminal.
+func (s *PTYSession) Send(input string) error {
+	if _, err := io.WriteString(s.pty, input); err != nil {
+		return fmt.Errorf("failed to write to terminal: %w", err)
+	}
+	return nil
+}
+
+// Types the line into the terminal and presses enter.
+func (s *PTYSession) SendLine(line string) error {
+	return s.Send(line + "\n")
+}
+
+// Changes the size of the terminal, which sends SIGWINCH to the command.
+func (s *PTYSession) Resize(rows, cols int) error {
+	return setPTYSize(s.pty, rows, cols)
+}
+
+// Returns everything the command wrote to the terminal so far.
+func (s *PTYSession) Transcript() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(s.transcript)
+}
+
+// Kills the command and all processes in its session, unless Wait already reaped it.
+func (s *PTYSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != nil {
+		return nil
+	}
+	return s.cmd.Cancel()
+}
+
+// Waits for the command to exit and returns its result, with the terminal transcript as stdout.
+// The terminal is closed afterwards.
+//
+// Returns:
+//   - *CommandResult: the outcome of the execution
+//   - error: the error reported by exec.Cmd.Wait, or the timeout or cancellation of the context
+func (s *PTYSession) Wait() (*CommandResult, error) {
+	err := s.cmd.Wait()
+	end := time.Now()
+	// The process state is written by Wait, Close must only look at the published copy
+	s.mu.Lock()
+	s.state = s.cmd.ProcessState
+	s.mu.Unlock()
+
+	// Processes that inherited the terminal may keep it open after the command exited
+	select {
+	case <-s.readDone:
+	case <-time.After(commandWaitDelay):
+	}
+	s.pty.Close()
+	<-s.readDone
+
+	result := &CommandResult{
+		Command:   s.command,
+		Stdout:    []byte(s.Transcript()),
+		ExitCode:  -1,
+		StartTime: s.start,
+		EndTime:   end,
+		Duration:  end.Sub(s.start),
+	}
+	result.StdoutSize = int64(len(result.Stdout))
+	result.setExitStatus(s.state)
+	s.resources.finish(result)
+
+	if err != nil {
+		err = contextError(s.ctx, result, err)
+	}
+	return result, err
+}
+
+// Copies the terminal output into the transcript until the terminal is closed.
+func (s *PTYSession) read() {
+	defer close(s.readDone)
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := s.pty.Read(buf)
+		if n > 0 {
+			s.mu.Lock()
+			s.pending = append(s.pending, buf[:n]...)
+			s.transcript = append(s.transcript, buf[:n]...)
+			close(s.changed)
+			s.changed = make(chan struct{})
+			s.mu.Unlock()
+		}
+		if err != nil {
+			// Linux reports EIO once the last process holding the terminal exited
+			return
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package system
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errPTYUnsupported = errors.New("pseudo-terminals are only supported on linux")
+
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errPTYUnsupported
+}
+
+func setPTYSize(pty *os.File, rows, cols int) error {
+	return errPTYUnsupported
+}
+
+func configurePTYProcess(cmd *exec.Cmd) {}
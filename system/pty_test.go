@@ -0,0 +1,105 @@
+//go:build linux
+
+package system
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunInteractive(t *testing.T) {
+	script := `printf "Name? "; read name; printf "Continue [y/n]? "; read answer; echo "hello $name ($answer)"`
+
+	result, err := NewCommand("sh", "-c", script).RunInteractive(context.Background(),
+		ExpectStep{Pattern: `Name\? $`, Send: "gopher\n", Timeout: 5 * time.Second},
+		ExpectStep{Pattern: `\[y/n\]\? `, Send: "y\n", Timeout: 5 * time.Second},
+	)
+	if err != nil {
+		t.Fatalf("Interactive session failed: %v", err)
+	}
+
+	transcript := result.StdoutString()
+	if !strings.Contains(transcript, "hello gopher (y)\r\n") {
+		t.Errorf("Unexpected transcript: %q", transcript)
+	}
+	if !result.Success() {
+		t.Errorf("Expected successful exit, got %d", result.ExitCode)
+	}
+}
+
+func TestPTYSessionSeesTerminal(t *testing.T) {
+	session, err := NewShellCommand(`[ -t 0 ] && [ -t 1 ] && echo "tty $(stty size)"`).StartPTY(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	defer session.Close()
+
+	if _, err := session.Expect(`tty 24 80`, 5*time.Second); err != nil {
+		t.Errorf("Command did not detect a terminal: %v, transcript %q", err, session.Transcript())
+	}
+
+	result, err := session.Wait()
+	if err != nil || !result.Success() {
+		t.Errorf("Unexpected result: %+v, %v", result, err)
+	}
+}
+
+func TestPTYSessionExpectFailures(t *testing.T) {
+	session, err := NewCommand("sh", "-c", "echo ready; sleep 30").StartPTY(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+
+	_, err = session.Expect(`never printed`, 100*time.Millisecond)
+	if !errors.Is(err, ErrExpectTimeout) {
+		t.Errorf("Expected ErrExpectTimeout, got: %v", err)
+	}
+
+	session.Close()
+	if _, err := session.Wait(); err == nil {
+		t.Error("Expected an error for a killed session")
+	}
+
+	session, err = NewCommand("echo", "done").StartPTY(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	_, err = session.Expect(`never printed`, 5*time.Second)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF once the command exited, got: %v", err)
+	}
+	session.Wait()
+}
+
+func TestPTYSessionCloseDuringWait(t *testing.T) {
+	session, err := NewCommand("sleep", "30").StartPTY(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+
+	done := make(chan *CommandResult, 1)
+	go func() {
+		result, _ := session.Wait()
+		done <- result
+	}()
+
+	// Close races with the waiting goroutine, the race detector must stay silent
+	if err := session.Close(); err != nil {
+		t.Errorf("Failed to close session: %v", err)
+	}
+	select {
+	case result := <-done:
+		if result.Signal != "killed" {
+			t.Errorf("Expected the command to be killed, got %+v", result)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Command was not killed")
+	}
+	if err := session.Close(); err != nil {
+		t.Errorf("Expected closing a finished session to succeed, got: %v", err)
+	}
+}
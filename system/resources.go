@@ -0,0 +1,82 @@
+package system
+
+import (
+	"errors"
+	"time"
+)
+
+// Returned (wrapped) if resource limits were requested on a platform without support for them.
+var ErrResourceLimitsUnsupported = errors.New("resource limits are not supported on this platform")
+
+// The mount point of the cgroup v2 hierarchy.
+const CgroupMountPoint = "/sys/fs/cgroup"
+
+// Limits the resources a command may consume. Zero values mean no limit.
+//
+// The rlimits apply to the started process and are inherited by its children. They are
+// set while the process is held stopped right after exec, before it runs any code. This
+// traces the child with ptrace, so commands with rlimits fail to start where ptrace is
+// denied, e.g. with the Yama ptrace_scope set to 3 or a seccomp profile blocking it, as
+// is common in containers.
+//
+// MemoryMax and CPUQuota place the process in a transient cgroup v2 below CgroupParent,
+// which confines all of its descendants as well. By default it is created below the
+// cgroup of the current process, which has to be delegated to the process, e.g. with
+// Delegate=yes in its systemd unit. As the kernel does not enable controllers for the
+// children of a cgroup that contains processes, CgroupParent usually points to an empty
+// cgroup of the delegated subtree.
+// If the cgroup cannot be created, for example because the hierarchy is not writable,
+// the command runs without it unless RequireCgroup is set. Only supported on Linux.
+//
+// Fields:
+//   - CPUTime: time.Duration - the CPU time after which the process is killed (RLIMIT_CPU)
+//   - AddressSpace: uint64 - the maximum size of the virtual memory in bytes (RLIMIT_AS)
+//   - OpenFiles: uint64 - the maximum number of open file descriptors (RLIMIT_NOFILE)
+//   - CoreSize: uint64 - the maximum size of core dumps in bytes (RLIMIT_CORE)
+//   - DisableCoreDumps: bool - prevent core dumps entirely, overriding CoreSize
+//   - MemoryMax: int64 - the memory limit of the cgroup in bytes (memory.max)
+//   - CPUQuota: float64 - the number of CPUs the cgroup may use, e.g. 0.5 (cpu.max); at least 0.01
+//   - CgroupParent: string - the directory of the cgroup the transient cgroup is created in,
+//     the cgroup of the current process if empty
+//   - RequireCgroup: bool - fail instead of running unconfined if the cgroup cannot be created
+type ResourceLimits struct {
+	CPUTime          time.Duration
+	AddressSpace     uint64
+	OpenFiles        uint64
+	CoreSize         uint64
+	DisableCoreDumps bool
+
+	MemoryMax     int64
+	CPUQuota      float64
+	CgroupParent  string
+	RequireCgroup bool
+}
+
+// Confines the command using rlimits and, if memory or CPU caps are set, a transient cgroup.
+// Peak memory and CPU usage are reported in the CommandResult.
+//
+// Example usage:
+//
+//	result, err := NewCommand("convert", input, output).
+//	  WithResourceLimits(ResourceLimits{
+//	    CPUTime:   time.Minute,
+//	    OpenFiles: 256,
+//	    MemoryMax: 512 * 1024 * 1024,
+//	    CPUQuota:  1.5,
+//	  }).
+//	  Run(ctx)
+//	fmt.Printf("peak memory: %d bytes\n", result.PeakMemory)
+func (c *Command) WithResourceLimits(limits ResourceLimits) *Command {
+	c.resourceLimits = &limits
+	return c
+}
+
+// Returns true if any rlimit is requested.
+func (l *ResourceLimits) hasRlimits() bool {
+	return l.CPUTime > 0 || l.AddressSpace > 0 || l.OpenFiles > 0 || l.CoreSize > 0 || l.DisableCoreDumps
+}
+
+// Returns true if any cgroup limit is requested.
+func (l *ResourceLimits) hasCgroupLimits() bool {
+	return l.MemoryMax > 0 || l.CPUQuota > 0
+}
@@ -0,0 +1,310 @@
+//go:build linux
+
+package system
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The period of the cgroup CPU bandwidth controller in microseconds.
+const cgroupCPUPeriod = 100000
+
+// The smallest quota the kernel accepts in cpu.max, in microseconds.
+const cgroupCPUMinQuota = 1000
+
+var cgroupCounter uint64
+
+// Applies the resource limits of a command around its start and collects its usage.
+type resourceControl struct {
+	limits    *ResourceLimits
+	cgroup    string
+	cgroupDir *os.File
+}
+
+// Prepares the exec.Cmd for the requested limits, creating the cgroup if needed.
+// Returns nil if the command has no limits.
+func prepareResources(c *Command, cmd *exec.Cmd) (*resourceControl, error) {
+	if c.resourceLimits == nil {
+		return nil, nil
+	}
+
+	rc := &resourceControl{limits: c.resourceLimits}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	if rc.limits.hasRlimits() {
+		// The child stops right after exec, so the limits are set before it runs
+		cmd.SysProcAttr.Ptrace = true
+	}
+
+	if rc.limits.hasCgroupLimits() {
+		path, err := createCgroup(rc.limits)
+		if err == nil {
+			rc.cgroupDir, err = os.Open(path)
+			if err != nil {
+				syscall.Rmdir(path)
+			}
+		}
+		if err != nil {
+			if rc.limits.RequireCgroup {
+				return nil, fmt.Errorf("failed to create cgroup: %w", err)
+			}
+		} else {
+			rc.cgroup = path
+			cmd.SysProcAttr.UseCgroupFD = true
+			cmd.SysProcAttr.CgroupFD = int(rc.cgroupDir.Fd())
+		}
+	}
+
+	return rc, nil
+}
+
+// Starts the command, applying the rlimits while it is stopped after exec.
+func (rc *resourceControl) start(cmd *exec.Cmd) error {
+	if rc == nil || !cmd.SysProcAttr.Ptrace {
+		return cmd.Start()
+	}
+
+	// The tracer is the thread that started the child, all ptrace calls must come from it
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pid := cmd.Process.Pid
+
+	var status syscall.WaitStatus
+	_, err := syscall.Wait4(pid, &status, syscall.WALL, nil)
+	if err == nil && !status.Stopped() {
+		err = fmt.Errorf("process did not stop after exec: %v", status)
+	}
+	if err == nil {
+		err = applyRlimits(pid, rc.limits)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		syscall.PtraceDetach(pid)
+		cmd.Wait()
+		return fmt.Errorf("failed to apply resource limits: %w", err)
+	}
+
+	if err := syscall.PtraceDetach(pid); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("failed to resume process: %w", err)
+	}
+	return nil
+}
+
+// Reports the usage measured by the cgroup and removes it.
+func (rc *resourceControl) finish(result *CommandResult) {
+	if rc == nil || rc.cgroup == "" {
+		return
+	}
+	defer rc.release()
+
+	if peak, err := readCgroupInt(filepath.Join(rc.cgroup, "memory.peak")); err == nil {
+		result.PeakMemory = peak
+	}
+	if stat, err := os.ReadFile(filepath.Join(rc.cgroup, "cpu.stat")); err == nil {
+		for _, line := range strings.Split(string(stat), "\n") {
+			key, value, _ := strings.Cut(line, " ")
+			usec, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "user_usec":
+				result.UserTime = time.Duration(usec) * time.Microsecond
+			case "system_usec":
+				result.SystemTime = time.Duration(usec) * time.Microsecond
+			}
+		}
+	}
+	result.Cgroup = rc.cgroup
+}
+
+// Kills any process left in the cgroup and removes it.
+func (rc *resourceControl) release() {
+	if rc == nil || rc.cgroup == "" {
+		return
+	}
+	rc.cgroupDir.Close()
+
+	os.WriteFile(filepath.Join(rc.cgroup, "cgroup.kill"), []byte("1"), 0)
+	for i := 0; i < 100; i++ {
+		if err := syscall.Rmdir(rc.cgroup); err == nil || err == syscall.ENOENT {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Creates a transient cgroup with the memory and CPU caps of the limits, below the
+// configured parent or the cgroup of the current process.
+func createCgroup(limits *ResourceLimits) (string, error) {
+	parent := limits.CgroupParent
+	if parent == "" {
+		content, err := os.ReadFile("/proc/self/cgroup")
+		if err != nil {
+			return "", err
+		}
+		cgroup, err := parseUnifiedCgroup(string(content))
+		if err != nil {
+			return "", err
+		}
+		parent = filepath.Join(CgroupMountPoint, cgroup)
+	}
+
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("%s is not a cgroup v2 hierarchy: %w", parent, err)
+	}
+
+	var controllers []string
+	if limits.MemoryMax > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.CPUQuota > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if err := enableControllers(parent, controllers); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(parent, fmt.Sprintf("system-command-%d-%d", os.Getpid(), atomic.AddUint64(&cgroupCounter, 1)))
+	if err := os.Mkdir(path, 0755); err != nil {
+		return "", err
+	}
+
+	var err error
+	if limits.MemoryMax > 0 {
+		err = os.WriteFile(filepath.Join(path, "memory.max"), []byte(strconv.FormatInt(limits.MemoryMax, 10)), 0)
+	}
+	if err == nil && limits.CPUQuota > 0 {
+		err = os.WriteFile(filepath.Join(path, "cpu.max"), []byte(cgroupCPUMax(limits.CPUQuota)), 0)
+	}
+	if err != nil {
+		syscall.Rmdir(path)
+		return "", fmt.Errorf("failed to configure cgroup: %w", err)
+	}
+	return path, nil
+}
+
+// Returns the cpu.max value for the number of CPUs. Quotas below the minimum of the
+// kernel, i.e. less than 1% of a CPU, are raised to it.
+func cgroupCPUMax(cpus float64) string {
+	quota := int64(math.Ceil(cpus * cgroupCPUPeriod))
+	if quota < cgroupCPUMinQuota {
+		quota = cgroupCPUMinQuota
+	}
+	return fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)
+}
+
+// Makes the controllers available to the children of the cgroup.
+func enableControllers(cgroup string, controllers []string) error {
+	content, err := os.ReadFile(filepath.Join(cgroup, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	enabled := strings.Fields(string(content))
+
+	for _, controller := range controllers {
+		if containsString(enabled, controller) {
+			continue
+		}
+		err := os.WriteFile(filepath.Join(cgroup, "cgroup.subtree_control"), []byte("+"+controller), 0)
+		if errors.Is(err, syscall.EBUSY) {
+			return fmt.Errorf("failed to enable %s controller in %s, the cgroup must not contain processes: %w",
+				controller, cgroup, err)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to enable %s controller in %s: %w", controller, cgroup, err)
+		}
+	}
+	return nil
+}
+
+// Returns the cgroup of a process in the unified hierarchy from the content of
+// /proc/<pid>/cgroup, relative to the mount point of the hierarchy.
+func parseUnifiedCgroup(content string) (string, error) {
+	for _, line := range strings.Split(content, "\n") {
+		cgroup, ok := strings.CutPrefix(strings.TrimSpace(line), "0::")
+		if !ok {
+			continue
+		}
+		// Outside of its cgroup namespace the path of the process is not below the mount point
+		if cgroup == "/.." || strings.HasPrefix(cgroup, "/../") {
+			return "", fmt.Errorf("cgroup %s is outside of the cgroup namespace", cgroup)
+		}
+		return filepath.Clean("/" + cgroup), nil
+	}
+	return "", fmt.Errorf("process is not in a cgroup v2 hierarchy")
+}
+
+// Sets the rlimits of another process.
+func applyRlimits(pid int, limits *ResourceLimits) error {
+	set := func(resource int, value uint64) error {
+		limit := syscall.Rlimit{Cur: value, Max: value}
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource),
+			uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+		if errno != 0 {
+			return fmt.Errorf("rlimit %d: %w", resource, errno)
+		}
+		return nil
+	}
+
+	if limits.CPUTime > 0 {
+		seconds := uint64(math.Ceil(limits.CPUTime.Seconds()))
+		if err := set(syscall.RLIMIT_CPU, seconds); err != nil {
+			return err
+		}
+	}
+	if limits.AddressSpace > 0 {
+		if err := set(syscall.RLIMIT_AS, limits.AddressSpace); err != nil {
+			return err
+		}
+	}
+	if limits.OpenFiles > 0 {
+		if err := set(syscall.RLIMIT_NOFILE, limits.OpenFiles); err != nil {
+			return err
+		}
+	}
+	if limits.DisableCoreDumps {
+		return set(syscall.RLIMIT_CORE, 0)
+	}
+	if limits.CoreSize > 0 {
+		return set(syscall.RLIMIT_CORE, limits.CoreSize)
+	}
+	return nil
+}
+
+// Returns the peak resident set size of a finished process in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// Linux reports the value in kilobytes
+		return int64(usage.Maxrss) * 1024
+	}
+	return 0
+}
+
+func readCgroupInt(path string) (int64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package system
+
+import (
+	"os"
+	"os/exec"
+)
+
+type resourceControl struct{}
+
+func prepareResources(c *Command, cmd *exec.Cmd) (*resourceControl, error) {
+	if c.resourceLimits == nil {
+		return nil, nil
+	}
+	return nil, ErrResourceLimitsUnsupported
+}
+
+func (rc *resourceControl) start(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+func (rc *resourceControl) finish(result *CommandResult) {}
+
+func (rc *resourceControl) release() {}
+
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,147 @@
+//go:build linux
+
+package system
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandRlimits(t *testing.T) {
+	result, err := NewCommand("sh", "-c", "ulimit -n; ulimit -c; ulimit -t").
+		WithResourceLimits(ResourceLimits{
+			OpenFiles:        64,
+			DisableCoreDumps: true,
+			CPUTime:          90 * time.Second,
+		}).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+
+	if result.StdoutString() != "64\n0\n90\n" {
+		t.Errorf("Limits were not applied before the command started, got %q", result.Stdout)
+	}
+}
+
+func TestPipelineAndPTYRlimits(t *testing.T) {
+	limits := ResourceLimits{OpenFiles: 64}
+
+	result, err := NewPipeline(
+		NewCommand("sh", "-c", "ulimit -n").WithResourceLimits(limits),
+		NewCommand("cat"),
+	).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run pipeline: %v", err)
+	}
+	if string(result.Stdout) != "64\n" {
+		t.Errorf("Limits were not applied to the pipeline stage, got %q", result.Stdout)
+	}
+
+	session, err := NewCommand("sh", "-c", "ulimit -n").WithResourceLimits(limits).StartPTY(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start command: %v", err)
+	}
+	defer session.Close()
+	if _, err := session.Expect(`64`, 5*time.Second); err != nil {
+		t.Errorf("Limits were not applied to the PTY command: %v, transcript: %q", err, session.Transcript())
+	}
+	session.Wait()
+}
+
+func TestCommandCPUTimeLimit(t *testing.T) {
+	result, err := NewCommand("sh", "-c", "while :; do :; done").
+		WithResourceLimits(ResourceLimits{CPUTime: time.Second}).
+		Run(context.Background())
+	if err == nil {
+		t.Fatal("Expected the busy loop to be killed")
+	}
+	if result.Signal == "" || result.UserTime+result.SystemTime < 500*time.Millisecond {
+		t.Errorf("Expected termination by signal after about a second of CPU time, got %+v", result)
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	result, err := NewCommand("sh", "-c", "i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done").Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	if result.PeakMemory <= 0 || result.UserTime+result.SystemTime <= 0 {
+		t.Errorf("Expected peak memory and CPU usage to be reported, got %d and %v",
+			result.PeakMemory, result.UserTime+result.SystemTime)
+	}
+}
+
+func TestCommandCgroupUnavailable(t *testing.T) {
+	parent := t.TempDir()
+	limits := ResourceLimits{MemoryMax: 64 * 1024 * 1024, CgroupParent: parent}
+
+	result, err := NewCommand("true").WithResourceLimits(limits).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Command should run unconfined without a cgroup hierarchy: %v", err)
+	}
+	if result.Cgroup != "" {
+		t.Errorf("Expected no cgroup, got %s", result.Cgroup)
+	}
+
+	limits.RequireCgroup = true
+	_, err = NewCommand("true").WithResourceLimits(limits).Run(context.Background())
+	if err == nil {
+		t.Error("Expected an error when the cgroup is required")
+	}
+}
+
+func TestCommandCgroup(t *testing.T) {
+	parent := CgroupMountPoint
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.subtree_control")); err != nil {
+		t.Skip("cgroup v2 is not mounted at", parent)
+	}
+
+	result, err := NewCommand("sh", "-c", "cat /proc/self/cgroup").
+		WithResourceLimits(ResourceLimits{MemoryMax: 64 * 1024 * 1024, CPUQuota: 0.5, RequireCgroup: true}).
+		Run(context.Background())
+	if err != nil {
+		t.Skip("cgroup v2 is not writable:", err)
+	}
+
+	if !strings.Contains(result.StdoutString(), filepath.Base(result.Cgroup)) {
+		t.Errorf("Command did not run in cgroup %s: %s", result.Cgroup, result.Stdout)
+	}
+	if _, err := os.Stat(result.Cgroup); !os.IsNotExist(err) {
+		t.Errorf("Transient cgroup %s was not removed", result.Cgroup)
+	}
+}
+
+func TestParseUnifiedCgroup(t *testing.T) {
+	cgroup, err := parseUnifiedCgroup("12:memory:/legacy\n0::/system.slice/agent.service\n")
+	if err != nil || cgroup != "/system.slice/agent.service" {
+		t.Errorf("Unexpected cgroup %q (%v)", cgroup, err)
+	}
+	if _, err := parseUnifiedCgroup("0::/../../system.slice/other.service\n"); err == nil {
+		t.Error("Expected an error for a cgroup outside of the namespace")
+	}
+	if _, err := parseUnifiedCgroup("4:memory:/\n"); err == nil {
+		t.Error("Expected an error without the unified hierarchy")
+	}
+}
+
+func TestCgroupCPUMax(t *testing.T) {
+	tests := []struct {
+		cpus     float64
+		expected string
+	}{
+		{1.5, "150000 100000"},
+		{0.01, "1000 100000"},
+		{0.001, "1000 100000"},
+	}
+
+	for _, test := range tests {
+		if value := cgroupCPUMax(test.cpus); value != test.expected {
+			t.Errorf("Unexpected cpu.max for %v CPUs. Expected: %q, Got: %q", test.cpus, test.expected, value)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"regexp"
+	"time"
+)
+
+// Decides whether a failed attempt is worth retrying. The result is nil if the command
+// could not be started at all.
+type RetryClassifier func(result *CommandResult, err error) bool
+
+// Configures how RunWithRetry repeats a failing command.
+//
+// Fields:
+//   - MaxAttempts: int - the total number of attempts including the first one
+//   - InitialDelay: time.Duration - the wait before the second attempt
+//   - MaxDelay: time.Duration - the upper bound of the wait between attempts, 0 for no bound
+//   - Multiplier: float64 - the factor the wait grows by after every attempt
+//   - Jitter: float64 - the fraction of the wait that is randomized, between 0 and 1
+//   - AttemptTimeout: time.Duration - the timeout of a single attempt, 0 for none
+//   - Retryable: RetryClassifier - decides which failures are retried, every command
+//     that started but failed if nil; not serialized
+type RetryPolicy struct {
+	MaxAttempts    int             `json:"max_attempts" bson:"max_attempts" yaml:"max_attempts"`
+	InitialDelay   time.Duration   `json:"initial_delay" bson:"initial_delay" yaml:"initial_delay"`
+	MaxDelay       time.Duration   `json:"max_delay" bson:"max_delay" yaml:"max_delay"`
+	Multiplier     float64         `json:"multiplier" bson:"multiplier" yaml:"multiplier"`
+	Jitter         float64         `json:"jitter" bson:"jitter" yaml:"jitter"`
+	AttemptTimeout time.Duration   `json:"attempt_timeout" bson:"attempt_timeout" yaml:"attempt_timeout"`
+	Retryable      RetryClassifier `json:"-" bson:"-" yaml:"-"`
+}
+
+// Holds a single attempt of a retried command.
+//
+// Fields:
+//   - Attempt: int - the number of the attempt, starting at 1
+//   - Result: *CommandResult - the result of the attempt, nil if the command could not be started
+//   - Err: error - the error of the attempt, nil if it succeeded; not serialized
+//   - Delay: time.Duration - the wait before the next attempt, 0 for the last one
+type RetryAttempt struct {
+	Attempt int            `json:"attempt" bson:"attempt" yaml:"attempt"`
+	Result  *CommandResult `json:"result" bson:"result" yaml:"result"`
+	Err     error          `json:"-" bson:"-" yaml:"-"`
+	Delay   time.Duration  `json:"delay" bson:"delay" yaml:"delay"`
+}
+
+// Holds the outcome of RunWithRetry.
+//
+// Fields:
+//   - Attempts: []RetryAttempt - every attempt in order
+//   - Result: *CommandResult - the result of the last attempt
+type RetryResult struct {
+	Attempts []RetryAttempt `json:"attempts" bson:"attempts" yaml:"attempts"`
+	Result   *CommandResult `json:"result" bson:"result" yaml:"result"`
+}
+
+// Returns a policy with 3 attempts, starting with a 1 second delay that doubles up
+// to 30 seconds, with 20% jitter.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+// Returns a classifier retrying commands that exited with one of the given codes.
+func RetryOnExitCodes(codes ...int) RetryClassifier {
+	return func(result *CommandResult, err error) bool {
+		if result == nil {
+			return false
+		}
+		for _, code := range codes {
+			if result.ExitCode == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Returns a classifier retrying commands whose stderr matches one of the regular expressions.
+// Panics if a pattern does not compile.
+//
+// Example usage:
+//
+//	policy.Retryable = RetryOnStderr(`Could not resolve host`, `(?i)connection reset`)
+func RetryOnStderr(patterns ...string) RetryClassifier {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		compiled[i] = regexp.MustCompile(pattern)
+	}
+
+	return func(result *CommandResult, err error) bool {
+		if result == nil {
+			return false
+		}
+		for _, re := range compiled {
+			if re.Match(result.Stderr) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Returns a classifier retrying a failure if any of the given classifiers does.
+func RetryOnAny(classifiers ...RetryClassifier) RetryClassifier {
+	return func(result *CommandResult, err error) bool {
+		for _, classifier := range classifiers {
+			if classifier(result, err) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Runs the command and repeats it according to the policy while it fails with a
+// retryable error. The wait between attempts grows exponentially and is randomized
+// by the jitter so that many callers do not retry in lockstep.
+//
+// The standard input of the command is read once and buffered, so every attempt receives
+// the same input. Every attempt runs a copy of the command, the command itself is not
+// modified. Retrying stops as soon as the context ends.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of all attempts
+//   - cmd: *Command - the command to run
+//   - policy: RetryPolicy - how often and when to retry
+//
+// Returns:
+//   - *RetryResult: every attempt made, also returned if all of them failed
+//   - error: the error of the last attempt if the command never succeeded
+//
+// Example usage:
+//
+//	policy := DefaultRetryPolicy()
+//	policy.Retryable = RetryOnStderr(`Could not resolve host`)
+//	retry, err := RunWithRetry(ctx, NewCommand("git", "fetch"), policy)
+//	for _, attempt := range retry.Attempts {
+//	  fmt.Printf("attempt %d: %v\n", attempt.Attempt, attempt.Err)
+//	}
+func RunWithRetry(ctx context.Context, cmd *Command, policy RetryPolicy) (*RetryResult, error) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 1
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = func(result *CommandResult, err error) bool {
+			return result != nil
+		}
+	}
+
+	var stdin []byte
+	if cmd.stdin != nil {
+		var err error
+		stdin, err = io.ReadAll(cmd.stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to buffer command input: %w", err)
+		}
+	}
+
+	retry := &RetryResult{}
+	delay := policy.InitialDelay
+
+	for attempt := 1; ; attempt++ {
+		attemptCmd := cmd.clone()
+		if stdin != nil {
+			attemptCmd.WithStdin(bytes.NewReader(stdin))
+		}
+
+		result, err := runAttempt(ctx, attemptCmd, policy.AttemptTimeout)
+		retry.Attempts = append(retry.Attempts, RetryAttempt{Attempt: attempt, Result: result, Err: err})
+		retry.Result = result
+
+		if err == nil {
+			return retry, nil
+		}
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil || !policy.Retryable(result, err) {
+			return retry, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		wait := jitter(delay, policy.Jitter)
+		retry.Attempts[len(retry.Attempts)-1].Delay = wait
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return retry, fmt.Errorf("giving up after %d attempts: %w", attempt, ctx.Err())
+		}
+
+		delay = time.Duration(float64(delay) * policy.Multiplier)
+		if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+	}
+}
+
+// Runs a single attempt, limited by the attempt timeout if one is set.
+func runAttempt(ctx context.Context, cmd *Command, timeout time.Duration) (*CommandResult, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return cmd.Run(ctx)
+}
+
+// Randomizes the delay by up to the given fraction in either direction.
+func jitter(delay time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || delay <= 0 {
+		return delay
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	spread := float64(delay) * fraction
+	return time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+}
@@ -0,0 +1,117 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  4,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     5 * time.Millisecond,
+		Multiplier:   2,
+		Jitter:       0.5,
+	}
+}
+
+func TestRunWithRetrySucceeds(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On(`^git fetch$`).Times(2).ExitCode(128).Stderr("fatal: Could not resolve host: github.com\n")
+	fake.On(`^git fetch$`).Stdout("done\n")
+
+	policy := testRetryPolicy()
+	policy.Retryable = RetryOnStderr(`Could not resolve host`)
+
+	retry, err := RunWithRetry(context.Background(), NewCommand("git", "fetch").WithExecutor(fake), policy)
+	if err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got: %v", err)
+	}
+
+	if len(retry.Attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(retry.Attempts))
+	}
+	for i, attempt := range retry.Attempts[:2] {
+		if attempt.Err == nil || attempt.Result.ExitCode != 128 || attempt.Delay <= 0 {
+			t.Errorf("Unexpected failed attempt %d: %+v", i+1, attempt)
+		}
+	}
+	if retry.Result.StdoutString() != "done\n" || retry.Attempts[2].Delay != 0 {
+		t.Errorf("Unexpected final attempt: %+v", retry.Attempts[2])
+	}
+}
+
+func TestRunWithRetryClassifier(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On(`^apt-get`).ExitCode(100).Stderr("E: Unable to locate package\n")
+
+	policy := testRetryPolicy()
+	policy.Retryable = RetryOnAny(RetryOnExitCodes(75), RetryOnStderr(`Temporary failure`))
+
+	retry, err := RunWithRetry(context.Background(), NewCommand("apt-get", "install", "foo").WithExecutor(fake), policy)
+	if err == nil {
+		t.Fatal("Expected a permanent failure")
+	}
+	if len(retry.Attempts) != 1 {
+		t.Errorf("Non-retryable failure must not be retried, got %d attempts", len(retry.Attempts))
+	}
+
+	retry, err = RunWithRetry(context.Background(), NewCommand("apt-get", "update").WithExecutor(fake), testRetryPolicy())
+	if err == nil || len(retry.Attempts) != 4 {
+		t.Errorf("Expected 4 failed attempts with the default classifier, got %d: %v", len(retry.Attempts), err)
+	}
+}
+
+func TestRunWithRetryReplaysStdin(t *testing.T) {
+	input := strings.NewReader("payload\n")
+	cmd := NewCommand("sh", "-c", `read line; echo "$line"; [ -e "$MARKER" ] || { touch "$MARKER"; exit 1; }`).
+		WithEnv("MARKER", t.TempDir()+"/marker").
+		WithStdin(input)
+	retry, err := RunWithRetry(context.Background(), cmd, testRetryPolicy())
+	if err != nil {
+		t.Fatalf("Expected the second attempt to succeed, got: %v", err)
+	}
+
+	if len(retry.Attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(retry.Attempts))
+	}
+	for _, attempt := range retry.Attempts {
+		if attempt.Result.StdoutString() != "payload\n" {
+			t.Errorf("Attempt %d did not receive the input, got %q", attempt.Attempt, attempt.Result.Stdout)
+		}
+	}
+	if cmd.stdin != input {
+		t.Error("Expected the input of the command to be left in place")
+	}
+}
+
+func TestRunWithRetryContext(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On(`.`).ExitCode(1)
+
+	policy := testRetryPolicy()
+	policy.InitialDelay = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := RunWithRetry(ctx, NewCommand("flaky").WithExecutor(fake), policy)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context deadline to stop retrying, got: %v", err)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second, 0.2)
+		if d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("Jittered delay %v out of range", d)
+		}
+	}
+	if jitter(time.Second, 0) != time.Second {
+		t.Error("Zero jitter must not change the delay")
+	}
+}
@@ -0,0 +1,400 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Provides the current time and timers to a Scheduler, so tests can control time.
+type Clock interface {
+	// Returns the current time.
+	Now() time.Time
+	// Returns a timer that sends the current time on its channel once the duration has elapsed.
+	NewTimer(d time.Duration) Timer
+}
+
+// A timer created by a Clock, behaving like time.Timer.
+type Timer interface {
+	// Returns the channel the time is sent on when the timer fires.
+	C() <-chan time.Time
+	// Prevents the timer from firing. Returns false if it already fired or was stopped.
+	Stop() bool
+	// Changes the timer to fire after the duration. It has to be stopped and its channel
+	// drained first. Returns true if the timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// The Clock of the operating system.
+type SystemClock struct{}
+
+// Returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Returns a timer backed by time.NewTimer(d).
+func (SystemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// The work of a scheduled job. The context is cancelled once the scheduler stops.
+type JobFunc func(ctx context.Context) error
+
+// Describes a job of a Scheduler.
+//
+// Fields:
+//   - Name: string - identifies the job, unique within a scheduler
+//   - Schedule: string - when the job runs, a cron expression as accepted by ParseSchedule
+//   - Jitter: time.Duration - the maximum random delay of every run, 0 for none
+//   - Func: JobFunc - the work to do
+type Job struct {
+	Name     string
+	Schedule string
+	Jitter   time.Duration
+	Func     JobFunc
+}
+
+// Holds the state and the result of the last run of a scheduled job.
+//
+// Fields:
+//   - Name: string - the name of the job
+//   - Schedule: string - the cron expression of the job
+//   - Next: time.Time - the next time the job is due, zero if it is never due again
+//   - Running: bool - true while the job runs
+//   - LastStart: time.Time - the moment the last run started
+//   - LastEnd: time.Time - the moment the last run finished
+//   - LastDuration: time.Duration - the duration of the last run
+//   - LastError: string - the error of the last run, empty if it succeeded
+//   - Runs: int - the number of finished runs
+//   - Failures: int - the number of runs that returned an error
+//   - Skipped: int - the number of runs skipped because the previous one was still running
+type JobStatus struct {
+	Name         string        `json:"name" bson:"name" yaml:"name"`
+	Schedule     string        `json:"schedule" bson:"schedule" yaml:"schedule"`
+	Next         time.Time     `json:"next" bson:"next" yaml:"next"`
+	Running      bool          `json:"running" bson:"running" yaml:"running"`
+	LastStart    time.Time     `json:"last_start" bson:"last_start" yaml:"last_start"`
+	LastEnd      time.Time     `json:"last_end" bson:"last_end" yaml:"last_end"`
+	LastDuration time.Duration `json:"last_duration" bson:"last_duration" yaml:"last_duration"`
+	LastError    string        `json:"last_error" bson:"last_error" yaml:"last_error"`
+	Runs         int           `json:"runs" bson:"runs" yaml:"runs"`
+	Failures     int           `json:"failures" bson:"failures" yaml:"failures"`
+	Skipped      int           `json:"skipped" bson:"skipped" yaml:"skipped"`
+}
+
+// Runs jobs on cron schedules inside the process. A job never overlaps with itself: if
+// it is still running when it is due again, that run is skipped and counted. The zero
+// value is ready to use.
+//
+// Fields:
+//   - Clock: Clock - the source of time, SystemClock by default
+//   - Location: *time.Location - the time zone of schedules without a CRON_TZ prefix, time.Local by default
+//
+// Example usage:
+//
+//	scheduler := NewScheduler()
+//	scheduler.Add(Job{
+//	  Name:     "cleanup",
+//	  Schedule: "0 3 * * *",
+//	  Jitter:   5 * time.Minute,
+//	  Func: func(ctx context.Context) error {
+//	    return file.DeleteAllExceptIgnored("/var/cache/app", ignored)
+//	  },
+//	})
+//	go scheduler.Run(ctx)
+type Scheduler struct {
+	Clock    Clock
+	Location *time.Location
+
+	mu      sync.Mutex
+	jobs    map[string]*scheduledJob
+	running bool
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+type scheduledJob struct {
+	job      Job
+	schedule Schedule
+	status   JobStatus
+}
+
+// Creates a Scheduler without jobs, using the system clock and the local time zone.
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		Clock:    SystemClock{},
+		Location: time.Local,
+		jobs:     make(map[string]*scheduledJob),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Adds a job to the scheduler. Jobs can be added before and while the scheduler runs.
+//
+// Parameters:
+//   - job: Job - the job to add
+//
+// Returns:
+//   - error: if the schedule is invalid, the function is missing or the name is taken
+func (s *Scheduler) Add(job Job) error {
+	if job.Func == nil {
+		return fmt.Errorf("job %s has no function", job.Name)
+	}
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.init()
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("job %s already exists", job.Name)
+	}
+
+	scheduled := &scheduledJob{
+		job:      job,
+		schedule: schedule,
+		status:   JobStatus{Name: job.Name, Schedule: job.Schedule},
+	}
+	scheduled.status.Next = schedule.Next(s.now())
+	s.jobs[job.Name] = scheduled
+	s.notify()
+	return nil
+}
+
+// Removes the job. A run in progress is not interrupted.
+// Returns false if there is no job with that name.
+func (s *Scheduler) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[name]; !exists {
+		return false
+	}
+	delete(s.jobs, name)
+	s.notify()
+	return true
+}
+
+// Returns the status of the job, false if there is no job with that name.
+func (s *Scheduler) Status(name string) (JobStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheduled, exists := s.jobs[name]
+	if !exists {
+		return JobStatus{}, false
+	}
+	return scheduled.status, true
+}
+
+// Returns the status of all jobs, ordered by name.
+func (s *Scheduler) Statuses() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, scheduled := range s.jobs {
+		statuses = append(statuses, scheduled.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Runs the due jobs until the context ends, then waits for the running jobs to finish.
+// The contexts of the jobs are cancelled together with the given context.
+//
+// Parameters:
+//   - ctx: context.Context - controls how long the scheduler runs
+//
+// Returns:
+//   - error: if the scheduler is already running
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return errors.New("scheduler is already running")
+	}
+	s.running = true
+	s.init()
+	// Jobs added so far are considered by the first iteration anyway
+	select {
+	case <-s.wake:
+	default:
+	}
+	s.mu.Unlock()
+
+	// A single timer is reused, as a stopped timer of an older Go version is only
+	// released once it would have fired
+	var timer Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		s.wg.Wait()
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.mu.Lock()
+		now := s.now()
+		var earliest time.Time
+		for _, scheduled := range s.jobs {
+			next := scheduled.status.Next
+			if next.IsZero() {
+				continue
+			}
+			if !next.After(now) {
+				s.dispatch(ctx, scheduled)
+				next = scheduled.schedule.Next(now)
+				scheduled.status.Next = next
+			}
+			if !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
+				earliest = next
+			}
+		}
+		s.mu.Unlock()
+
+		var expired <-chan time.Time
+		if !earliest.IsZero() {
+			if timer == nil {
+				timer = s.clock().NewTimer(earliest.Sub(now))
+			} else {
+				timer.Reset(earliest.Sub(now))
+			}
+			expired = timer.C()
+		}
+
+		select {
+		case <-expired:
+		case <-s.wake:
+		case <-ctx.Done():
+			return nil
+		}
+		if timer != nil {
+			stopTimer(timer)
+		}
+	}
+}
+
+// Stops the timer and drains its channel, so it can be reset.
+func stopTimer(timer Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C():
+		default:
+		}
+	}
+}
+
+// Starts a run of the job unless the previous one is still running.
+// Must be called with the mutex held.
+func (s *Scheduler) dispatch(ctx context.Context, scheduled *scheduledJob) {
+	if scheduled.status.Running {
+		scheduled.status.Skipped++
+		return
+	}
+	scheduled.status.Running = true
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		if scheduled.job.Jitter > 0 {
+			delay := time.Duration(rand.Int63n(int64(scheduled.job.Jitter)))
+			timer := s.clock().NewTimer(delay)
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+				s.mu.Lock()
+				scheduled.status.Running = false
+				s.mu.Unlock()
+				return
+			}
+		}
+
+		start := s.clock().Now()
+		s.mu.Lock()
+		scheduled.status.LastStart = start
+		s.mu.Unlock()
+
+		err := runJob(ctx, scheduled.job)
+		end := s.clock().Now()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		scheduled.status.Running = false
+		scheduled.status.LastEnd = end
+		scheduled.status.LastDuration = end.Sub(start)
+		scheduled.status.Runs++
+		scheduled.status.LastError = ""
+		if err != nil {
+			scheduled.status.Failures++
+			scheduled.status.LastError = err.Error()
+		}
+	}()
+}
+
+// Runs the job function, turning a panic into an error.
+func runJob(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job %s panicked: %v", job.Name, recovered)
+		}
+	}()
+	return job.Func(ctx)
+}
+
+// Creates the job table and the wake-up channel of a Scheduler that was not created by
+// NewScheduler. Must be called with the mutex held.
+func (s *Scheduler) init() {
+	if s.jobs == nil {
+		s.jobs = make(map[string]*scheduledJob)
+	}
+	if s.wake == nil {
+		s.wake = make(chan struct{}, 1)
+	}
+}
+
+// Returns the clock of the scheduler, SystemClock if none is set.
+func (s *Scheduler) clock() Clock {
+	if s.Clock == nil {
+		return SystemClock{}
+	}
+	return s.Clock
+}
+
+// Returns the current time in the time zone of the scheduler.
+func (s *Scheduler) now() time.Time {
+	location := s.Location
+	if location == nil {
+		location = time.Local
+	}
+	return s.clock().Now().In(location)
+}
+
+// Wakes up the run loop to reconsider the jobs. Must be called with the mutex held.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,359 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A Clock that only advances when told to.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	timer := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	timer.Reset(d)
+	return timer
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.deadline.After(c.now) {
+			pending = append(pending, waiter)
+			continue
+		}
+		waiter.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, waiter := range t.clock.waiters {
+		if waiter == t {
+			t.clock.waiters = append(t.clock.waiters[:i], t.clock.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if d <= 0 {
+		t.ch <- t.clock.now
+		return active
+	}
+	t.deadline = t.clock.now.Add(d)
+	t.clock.waiters = append(t.clock.waiters, t)
+	return active
+}
+
+// Blocks until at least n goroutines wait for the clock.
+func (c *fakeClock) waitForWaiters(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		waiting := len(c.waiters)
+		c.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d clock waiters", n)
+}
+
+func waitForJobStatus(t *testing.T, scheduler *Scheduler, name string, condition func(JobStatus) bool) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, ok := scheduler.Status(name); ok && condition(status) {
+			return status
+		}
+		time.Sleep(time.Millisecond)
+	}
+	status, _ := scheduler.Status(name)
+	t.Fatalf("Timed out waiting for job %s, status: %+v", name, status)
+	return status
+}
+
+func newTestScheduler() (*Scheduler, *fakeClock) {
+	clock := newFakeClock()
+	scheduler := NewScheduler()
+	scheduler.Clock = clock
+	scheduler.Location = time.UTC
+	return scheduler, clock
+}
+
+func TestSchedulerRun(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	start := clock.Now()
+
+	calls := 0
+	err := scheduler.Add(Job{Name: "cleanup", Schedule: "@every 1m", Func: func(ctx context.Context) error {
+		calls++
+		if calls == 2 {
+			return errors.New("disk busy")
+		}
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+	scheduler.Add(Job{Name: "panics", Schedule: "*/5 * * * *", Func: func(ctx context.Context) error {
+		panic("boom")
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- scheduler.Run(ctx) }()
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	status := waitForJobStatus(t, scheduler, "cleanup", func(s JobStatus) bool { return s.Runs == 1 })
+	if !status.LastStart.Equal(start.Add(time.Minute)) || status.LastError != "" || status.Failures != 0 {
+		t.Errorf("Unexpected status after the first run: %+v", status)
+	}
+	if !status.Next.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("Unexpected next run. Expected: %s, Got: %s", start.Add(2*time.Minute), status.Next)
+	}
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	status = waitForJobStatus(t, scheduler, "cleanup", func(s JobStatus) bool { return s.Runs == 2 })
+	if status.LastError != "disk busy" || status.Failures != 1 {
+		t.Errorf("Expected the failure to be recorded, got: %+v", status)
+	}
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(3 * time.Minute)
+	status = waitForJobStatus(t, scheduler, "panics", func(s JobStatus) bool { return s.Runs == 1 })
+	if !strings.Contains(status.LastError, "panicked: boom") || status.Failures != 1 {
+		t.Errorf("Expected the panic to be recorded as failure, got: %+v", status)
+	}
+	if !status.Next.Equal(start.Add(10 * time.Minute)) {
+		t.Errorf("Unexpected next run. Expected: %s, Got: %s", start.Add(10*time.Minute), status.Next)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected Run to return nil, got: %v", err)
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+
+	release := make(chan struct{})
+	scheduler.Add(Job{Name: "backup", Schedule: "@every 1m", Func: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduler.Run(ctx)
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	waitForJobStatus(t, scheduler, "backup", func(s JobStatus) bool { return s.Running })
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	status := waitForJobStatus(t, scheduler, "backup", func(s JobStatus) bool { return s.Skipped == 1 })
+	if status.Runs != 0 || !status.Running {
+		t.Errorf("Expected the first run to continue, got: %+v", status)
+	}
+
+	close(release)
+	status = waitForJobStatus(t, scheduler, "backup", func(s JobStatus) bool { return s.Runs == 1 })
+	if status.Running || status.LastDuration != time.Minute {
+		t.Errorf("Unexpected status after the run finished: %+v", status)
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	start := clock.Now()
+
+	scheduler.Add(Job{Name: "report", Schedule: "@every 1m", Jitter: 30 * time.Second, Func: func(ctx context.Context) error {
+		return nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduler.Run(ctx)
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	// The scheduler waits for the next run, the job for its jitter
+	clock.waitForWaiters(t, 2)
+	if status, _ := scheduler.Status("report"); status.Runs != 0 || !status.Running {
+		t.Errorf("Expected the run to be delayed, got: %+v", status)
+	}
+
+	clock.Advance(30 * time.Second)
+	status := waitForJobStatus(t, scheduler, "report", func(s JobStatus) bool { return s.Runs == 1 })
+	if status.LastStart.Before(start.Add(time.Minute)) || status.LastStart.After(start.Add(90*time.Second)) {
+		t.Errorf("Expected the run to start within the jitter, got %s", status.LastStart)
+	}
+}
+
+func TestSchedulerStopWaitsForJobs(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+
+	scheduler.Add(Job{Name: "sync", Schedule: "@every 1m", Func: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- scheduler.Run(ctx) }()
+
+	clock.waitForWaiters(t, 1)
+	if err := scheduler.Run(ctx); err == nil {
+		t.Error("Expected an error when running the scheduler twice")
+	}
+
+	clock.Advance(time.Minute)
+	waitForJobStatus(t, scheduler, "sync", func(s JobStatus) bool { return s.Running })
+	cancel()
+	<-done
+
+	status, _ := scheduler.Status("sync")
+	if status.Running || status.Runs != 1 || status.LastError != context.Canceled.Error() {
+		t.Errorf("Expected Run to wait for the cancelled job, got: %+v", status)
+	}
+}
+
+func TestSchedulerAddRemove(t *testing.T) {
+	scheduler, _ := newTestScheduler()
+	noop := func(ctx context.Context) error { return nil }
+
+	if err := scheduler.Add(Job{Name: "invalid", Schedule: "* * *", Func: noop}); err == nil {
+		t.Error("Expected an error for an invalid schedule")
+	}
+	if err := scheduler.Add(Job{Name: "missing", Schedule: "@daily"}); err == nil {
+		t.Error("Expected an error for a job without function")
+	}
+	scheduler.Add(Job{Name: "rotate", Schedule: "@daily", Func: noop})
+	scheduler.Add(Job{Name: "berlin", Schedule: "CRON_TZ=Europe/Berlin 0 12 * * *", Func: noop})
+	if err := scheduler.Add(Job{Name: "rotate", Schedule: "@hourly", Func: noop}); err == nil {
+		t.Error("Expected an error for a duplicate job name")
+	}
+
+	statuses := scheduler.Statuses()
+	if len(statuses) != 2 || statuses[0].Name != "berlin" || statuses[1].Name != "rotate" {
+		t.Fatalf("Unexpected statuses: %+v", statuses)
+	}
+	if expected := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC); !statuses[1].Next.Equal(expected) {
+		t.Errorf("Unexpected next run. Expected: %s, Got: %s", expected, statuses[1].Next)
+	}
+	if _, err := time.LoadLocation("Europe/Berlin"); err == nil {
+		if expected := time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC); !statuses[0].Next.Equal(expected) {
+			t.Errorf("Unexpected next run in Berlin. Expected: %s, Got: %s", expected, statuses[0].Next)
+		}
+	}
+
+	if !scheduler.Remove("rotate") || scheduler.Remove("rotate") {
+		t.Error("Expected the job to be removed exactly once")
+	}
+	if _, ok := scheduler.Status("rotate"); ok {
+		t.Error("Expected no status for a removed job")
+	}
+}
+
+func TestSchedulerZeroValue(t *testing.T) {
+	var scheduler Scheduler
+	if scheduler.Remove("missing") || len(scheduler.Statuses()) != 0 {
+		t.Error("Expected an empty scheduler")
+	}
+
+	ran := make(chan struct{}, 1)
+	err := scheduler.Add(Job{Name: "tick", Schedule: "@every 10ms", Func: func(ctx context.Context) error {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- scheduler.Run(ctx) }()
+
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Error("Job did not run with the system clock")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestSchedulerReusesTimer(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	noop := func(ctx context.Context) error { return nil }
+	scheduler.Add(Job{Name: "cleanup", Schedule: "0 3 * * *", Func: noop})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduler.Run(ctx)
+	clock.waitForWaiters(t, 1)
+
+	// Every change wakes the scheduler, which must not leave a timer behind each time
+	for i := 0; i < 5; i++ {
+		scheduler.Add(Job{Name: "report", Schedule: "0 4 * * *", Func: noop})
+		time.Sleep(10 * time.Millisecond)
+		scheduler.Remove("report")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	clock.mu.Lock()
+	waiting := len(clock.waiters)
+	clock.mu.Unlock()
+	if waiting != 1 {
+		t.Errorf("Expected a single pending timer, got %d", waiting)
+	}
+}
@@ -0,0 +1,247 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Common states sent to the service manager with Notify.
+const (
+	// The service finished starting up, required for units with Type=notify.
+	NotifyReady = "READY=1"
+	// The service is reloading its configuration, send NotifyReady once done.
+	NotifyReloading = "RELOADING=1"
+	// The service is shutting down.
+	NotifyStopping = "STOPPING=1"
+	// Keeps the watchdog of units with WatchdogSec from restarting the service.
+	NotifyWatchdog = "WATCHDOG=1"
+)
+
+// The syslog priorities understood by the journal as line prefixes.
+type JournalPriority int
+
+const (
+	PriorityEmergency JournalPriority = iota
+	PriorityAlert
+	PriorityCritical
+	PriorityError
+	PriorityWarning
+	PriorityNotice
+	PriorityInfo
+	PriorityDebug
+)
+
+// Returns true if the process was started by systemd as part of a unit, detected by the
+// INVOCATION_ID or NOTIFY_SOCKET variables systemd sets for its services.
+func UnderSystemd() bool {
+	return os.Getenv("INVOCATION_ID") != "" || os.Getenv("NOTIFY_SOCKET") != ""
+}
+
+// Returns true if the system was booted with systemd as init system.
+func SystemdBooted() bool {
+	info, err := os.Stat("/run/systemd/system")
+	return err == nil && info.IsDir()
+}
+
+// Sends state updates to the service manager over the socket named by NOTIFY_SOCKET,
+// like sd_notify(3). Several states can be combined, each one a KEY=VALUE line.
+//
+// Parameters:
+//   - states: ...string - the states to send, e.g. NotifyReady or NotifyStatus("...")
+//
+// Returns:
+//   - bool: false if the process does not run under a service manager supporting notifications
+//   - error: if the notification could not be sent
+//
+// Example usage:
+//
+//	// after the listeners are open
+//	if _, err := Notify(NotifyReady, NotifyStatus("serving on :8080")); err != nil {
+//	  log.Printf("failed to notify systemd: %v", err)
+//	}
+func Notify(states ...string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// Abstract socket names start with '@'
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	var message bytes.Buffer
+	for _, state := range states {
+		message.WriteString(state)
+		message.WriteByte('\n')
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(message.Bytes()); err != nil {
+		return false, fmt.Errorf("failed to send notification: %w", err)
+	}
+	return true, nil
+}
+
+// Returns the state describing the service status, shown by 'systemctl status'.
+func NotifyStatus(status string) string {
+	return "STATUS=" + status
+}
+
+// Returns the interval in which the service manager expects NotifyWatchdog, as configured
+// with WatchdogSec. Returns 0 if the watchdog is not enabled for this process.
+//
+// Returns:
+//   - time.Duration: the watchdog timeout, notifications should be sent at half of it
+//   - error: if WATCHDOG_USEC or WATCHDOG_PID are malformed
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	microseconds, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || microseconds <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+
+	// The watchdog may be meant for another process, e.g. the shell script that exec'd us
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" {
+		watchdogPID, err := strconv.Atoi(pid)
+		if err != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %q", pid)
+		}
+		if watchdogPID != os.Getpid() {
+			return 0, nil
+		}
+	}
+	return time.Duration(microseconds) * time.Microsecond, nil
+}
+
+// Sends NotifyWatchdog at half the watchdog interval until the context ends. Returns
+// immediately if the watchdog is not enabled.
+//
+// Parameters:
+//   - ctx: context.Context - stops the notifications when done
+//
+// Returns:
+//   - error: if the watchdog configuration is invalid or a notification could not be sent
+//
+// Example usage:
+//
+//	go func() {
+//	  if err := RunWatchdog(ctx); err != nil {
+//	    log.Printf("watchdog stopped: %v", err)
+//	  }
+//	}()
+func RunWatchdog(ctx context.Context) error {
+	interval, err := WatchdogInterval()
+	if err != nil || interval == 0 {
+		return err
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		if _, err := Notify(NotifyWatchdog); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Writes log lines with a syslog priority prefix like "<3>", which the journal uses as the
+// priority of lines a service writes to stdout or stderr. Lines that already carry a prefix
+// are passed through unchanged. Partial lines are buffered until they are complete or Flush
+// is called. Safe for concurrent use.
+type JournalWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	priority JournalPriority
+	pending  []byte
+}
+
+// Creates a JournalWriter prefixing every line written to w with the priority.
+//
+// Parameters:
+//   - w: io.Writer - the destination, typically os.Stderr
+//   - priority: JournalPriority - the priority of the lines
+//
+// Returns:
+//   - *JournalWriter: the writer
+//
+// Example usage:
+//
+//	errorLog := log.New(NewJournalWriter(os.Stderr, PriorityError), "", 0)
+//	errorLog.Printf("failed to connect to %s: %v", address, err)
+func NewJournalWriter(w io.Writer, priority JournalPriority) *JournalWriter {
+	return &JournalWriter{w: w, priority: priority}
+}
+
+// Writes the complete lines of p with prefixes and buffers the rest.
+func (j *JournalWriter) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.pending = append(j.pending, p...)
+	end := bytes.LastIndexByte(j.pending, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+
+	complete := j.pending[:end+1]
+	var out bytes.Buffer
+	for len(complete) > 0 {
+		line := complete[:bytes.IndexByte(complete, '\n')+1]
+		complete = complete[len(line):]
+		j.writeLine(&out, line)
+	}
+	j.pending = append(j.pending[:0], j.pending[end+1:]...)
+
+	if _, err := j.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Writes a buffered partial line, terminated with a newline.
+func (j *JournalWriter) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.pending) == 0 {
+		return nil
+	}
+	var out bytes.Buffer
+	j.writeLine(&out, append(j.pending, '\n'))
+	j.pending = j.pending[:0]
+	_, err := j.w.Write(out.Bytes())
+	return err
+}
+
+// Appends the line to out, prefixed with the priority unless it has one already.
+func (j *JournalWriter) writeLine(out *bytes.Buffer, line []byte) {
+	if !hasPriorityPrefix(line) {
+		fmt.Fprintf(out, "<%d>", j.priority)
+	}
+	out.Write(line)
+}
+
+// Returns true if the line starts with a priority prefix like "<4>".
+func hasPriorityPrefix(line []byte) bool {
+	return len(line) >= 3 && line[0] == '<' && line[1] >= '0' && line[1] <= '7' && line[2] == '>'
+}
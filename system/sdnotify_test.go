@@ -0,0 +1,119 @@
+//go:build unix
+
+package system
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen on notify socket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func readNotification(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buffer := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatalf("Failed to read notification: %v", err)
+	}
+	return string(buffer[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	t.Setenv("INVOCATION_ID", "")
+	if sent, err := Notify(NotifyReady); sent || err != nil {
+		t.Errorf("Expected no notification without a socket, got %v (%v)", sent, err)
+	}
+	if UnderSystemd() {
+		t.Error("Expected not to run under systemd")
+	}
+
+	conn := listenNotifySocket(t)
+	if !UnderSystemd() {
+		t.Error("Expected to run under systemd with NOTIFY_SOCKET set")
+	}
+	sent, err := Notify(NotifyReady, NotifyStatus("serving on :8080"))
+	if !sent || err != nil {
+		t.Fatalf("Failed to notify: %v", err)
+	}
+	if message := readNotification(t, conn); message != "READY=1\nSTATUS=serving on :8080\n" {
+		t.Errorf("Unexpected notification: %q", message)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+	if _, err := Notify(NotifyStopping); err == nil {
+		t.Error("Expected an error for a missing socket")
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	t.Setenv("WATCHDOG_PID", "")
+	if interval, err := WatchdogInterval(); interval != 0 || err != nil {
+		t.Errorf("Expected a disabled watchdog, got %s (%v)", interval, err)
+	}
+
+	t.Setenv("WATCHDOG_USEC", "100000")
+	t.Setenv("WATCHDOG_PID", "1")
+	if interval, _ := WatchdogInterval(); interval != 0 {
+		t.Errorf("Expected the watchdog of another process to be ignored, got %s", interval)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if interval, err := WatchdogInterval(); interval != 100*time.Millisecond || err != nil {
+		t.Errorf("Unexpected watchdog interval: %s (%v)", interval, err)
+	}
+	t.Setenv("WATCHDOG_USEC", "soon")
+	if _, err := WatchdogInterval(); err == nil {
+		t.Error("Expected an error for a malformed WATCHDOG_USEC")
+	}
+
+	t.Setenv("WATCHDOG_USEC", "100000")
+	conn := listenNotifySocket(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- RunWatchdog(ctx) }()
+
+	for i := 0; i < 2; i++ {
+		if message := readNotification(t, conn); message != "WATCHDOG=1\n" {
+			t.Errorf("Unexpected notification: %q", message)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected the watchdog to stop cleanly, got: %v", err)
+	}
+}
+
+func TestJournalWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer := NewJournalWriter(&out, PriorityWarning)
+
+	writer.Write([]byte("disk almost full\nretrying "))
+	writer.Write([]byte("in 5s\n<3>already prefixed\nno newline"))
+	if out.String() != "<4>disk almost full\n<4>retrying in 5s\n<3>already prefixed\n" {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+
+	writer.Flush()
+	if out.String() != "<4>disk almost full\n<4>retrying in 5s\n<3>already prefixed\n<4>no newline\n" {
+		t.Errorf("Unexpected output after flush: %q", out.String())
+	}
+}
@@ -0,0 +1,307 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Returned (wrapped) by ShutdownCoordinator.Shutdown if the hooks did not finish within
+// the global deadline.
+var ErrShutdownTimeout = errors.New("shutdown did not finish in time")
+
+// The global deadline of a ShutdownCoordinator created with a timeout of 0.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// The timeout of a shutdown hook registered without one.
+const DefaultShutdownHookTimeout = 10 * time.Second
+
+// Releases a component during shutdown. The context expires once the timeout of the hook
+// or the global deadline passed.
+type ShutdownHook func(ctx context.Context) error
+
+// Reloads the configuration of a component when the process receives SIGHUP.
+type ReloadHook func(ctx context.Context) error
+
+// Coordinates the shutdown of a service. Components register hooks as they start, and
+// the hooks run one after the other once the process receives SIGINT or SIGTERM, or
+// Shutdown is called: hooks with a higher priority first, hooks of equal priority in
+// reverse order of registration, so components are released in the opposite order they
+// were set up. SIGHUP runs the reload hooks instead.
+//
+// If the hooks do not finish within the global deadline, or a second SIGINT or SIGTERM
+// arrives during shutdown, ExitFunc is called with exit code 1 to force the process down.
+//
+// Fields:
+//   - ExitFunc: func(code int) - ends the process when shutdown has to be forced, os.Exit by default
+//   - ErrorHandler: func(err error) - receives the errors of shutdowns and reloads
+//     triggered by signals, may be nil
+//
+// Example usage:
+//
+//	coordinator := NewShutdownCoordinator(30 * time.Second)
+//	coordinator.Listen()
+//
+//	server := startServer(coordinator.Context())
+//	coordinator.OnShutdown("http server", 10, 15*time.Second, server.Shutdown)
+//	coordinator.OnShutdown("database", 0, 5*time.Second, func(ctx context.Context) error {
+//	  return db.Close()
+//	})
+//	coordinator.OnReload("config", loadConfig)
+//
+//	if err := coordinator.Wait(); err != nil {
+//	  log.Printf("unclean shutdown: %v", err)
+//	}
+type ShutdownCoordinator struct {
+	ExitFunc     func(code int)
+	ErrorHandler func(err error)
+
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	hooks    []shutdownHook
+	reloads  []reloadHook
+	started  bool
+	done     chan struct{}
+	err      error
+	signals  chan os.Signal
+	reloadMu sync.Mutex
+}
+
+type shutdownHook struct {
+	name     string
+	priority int
+	timeout  time.Duration
+	hook     ShutdownHook
+	order    int
+}
+
+type reloadHook struct {
+	name string
+	hook ReloadHook
+}
+
+// Creates a ShutdownCoordinator. Signals are only handled once Listen was called.
+//
+// Parameters:
+//   - timeout: time.Duration - the global deadline for all shutdown hooks, DefaultShutdownTimeout if 0
+//
+// Returns:
+//   - *ShutdownCoordinator: the coordinator
+func NewShutdownCoordinator(timeout time.Duration) *ShutdownCoordinator {
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ShutdownCoordinator{
+		ExitFunc: os.Exit,
+		timeout:  timeout,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// Returns the root context of the service, which is cancelled as soon as the shutdown begins.
+func (s *ShutdownCoordinator) Context() context.Context {
+	return s.ctx
+}
+
+// Returns a channel that is closed once all shutdown hooks have finished.
+func (s *ShutdownCoordinator) Done() <-chan struct{} {
+	return s.done
+}
+
+// Registers a hook that runs during shutdown.
+//
+// Parameters:
+//   - name: string - describes the component in errors
+//   - priority: int - hooks with a higher priority run first
+//   - timeout: time.Duration - how long the hook may take, DefaultShutdownHookTimeout if 0
+//   - hook: ShutdownHook - releases the component
+func (s *ShutdownCoordinator) OnShutdown(name string, priority int, timeout time.Duration, hook ShutdownHook) {
+	if timeout <= 0 {
+		timeout = DefaultShutdownHookTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{
+		name:     name,
+		priority: priority,
+		timeout:  timeout,
+		hook:     hook,
+		order:    len(s.hooks),
+	})
+}
+
+// Registers a hook that runs when the process receives SIGHUP or Reload is called.
+// Reload hooks run in order of registration.
+func (s *ShutdownCoordinator) OnReload(name string, hook ReloadHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloads = append(s.reloads, reloadHook{name: name, hook: hook})
+}
+
+// Starts handling SIGINT and SIGTERM by shutting down, and SIGHUP by reloading.
+// Signals are handled until the shutdown has finished.
+func (s *ShutdownCoordinator) Listen() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.signals != nil {
+		return
+	}
+
+	s.signals = make(chan os.Signal, 1)
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(s.signals)
+		for {
+			select {
+			case sig := <-s.signals:
+				s.handleSignal(sig)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Runs the reload hooks one after the other. Reloads do not overlap.
+//
+// Returns:
+//   - error: the joined errors of all failed reload hooks
+func (s *ShutdownCoordinator) Reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.mu.Lock()
+	reloads := append([]reloadHook(nil), s.reloads...)
+	s.mu.Unlock()
+
+	var errs []error
+	for _, reload := range reloads {
+		if err := reload.hook(s.ctx); err != nil {
+			errs = append(errs, fmt.Errorf("reload %s: %w", reload.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Cancels the root context and runs the shutdown hooks. Further calls wait for the
+// first shutdown to finish and return its result.
+//
+// If the hooks exceed the global deadline, ExitFunc is called with exit code 1. Should
+// ExitFunc return, as it does in tests, the remaining hooks are skipped.
+//
+// Returns:
+//   - error: the joined errors of all failed hooks, wrapping ErrShutdownTimeout if the
+//     global deadline expired
+func (s *ShutdownCoordinator) Shutdown() error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		<-s.done
+		return s.err
+	}
+	s.started = true
+	hooks := append([]shutdownHook(nil), s.hooks...)
+	s.mu.Unlock()
+
+	s.cancel()
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		if hooks[i].priority != hooks[j].priority {
+			return hooks[i].priority > hooks[j].priority
+		}
+		return hooks[i].order > hooks[j].order
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	var errs []error
+	for _, hook := range hooks {
+		if err := runShutdownHook(ctx, hook); err != nil {
+			errs = append(errs, err)
+		}
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%w within %s", ErrShutdownTimeout, s.timeout))
+			s.ExitFunc(1)
+			break
+		}
+	}
+
+	s.err = errors.Join(errs...)
+	close(s.done)
+	return s.err
+}
+
+// Waits until the shutdown has finished and returns its result.
+func (s *ShutdownCoordinator) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Shuts down on the first SIGINT or SIGTERM and forces the exit on the second one.
+func (s *ShutdownCoordinator) handleSignal(sig os.Signal) {
+	if sig == syscall.SIGHUP {
+		go func() {
+			if err := s.Reload(); err != nil {
+				s.handleError(err)
+			}
+		}()
+		return
+	}
+
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+
+	if started {
+		s.ExitFunc(1)
+		return
+	}
+	go func() {
+		if err := s.Shutdown(); err != nil {
+			s.handleError(err)
+		}
+	}()
+}
+
+func (s *ShutdownCoordinator) handleError(err error) {
+	if s.ErrorHandler != nil {
+		s.ErrorHandler(err)
+	}
+}
+
+// Runs a single hook, abandoning it once its timeout expired.
+func runShutdownHook(ctx context.Context, hook shutdownHook) error {
+	ctx, cancel := context.WithTimeout(ctx, hook.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- hook.hook(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("shutdown %s: %w", hook.name, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("shutdown %s: %w", hook.name, ctx.Err())
+	}
+}
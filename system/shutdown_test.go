@@ -0,0 +1,154 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownCoordinatorOrder(t *testing.T) {
+	coordinator := NewShutdownCoordinator(time.Second)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) ShutdownHook {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	coordinator.OnShutdown("database", 0, 0, record("database"))
+	coordinator.OnShutdown("cache", 0, 0, record("cache"))
+	coordinator.OnShutdown("http", 10, 0, record("http"))
+	coordinator.OnShutdown("metrics", -1, 0, func(ctx context.Context) error {
+		record("metrics")(ctx)
+		return errors.New("flush failed")
+	})
+
+	err := coordinator.Shutdown()
+	if err == nil || !strings.Contains(err.Error(), "shutdown metrics: flush failed") {
+		t.Errorf("Expected the error of the metrics hook, got: %v", err)
+	}
+	if strings.Join(order, ",") != "http,cache,database,metrics" {
+		t.Errorf("Unexpected order. Expected: %s, Got: %s", "http,cache,database,metrics", strings.Join(order, ","))
+	}
+
+	if coordinator.Context().Err() == nil {
+		t.Error("Expected the root context to be cancelled")
+	}
+	select {
+	case <-coordinator.Done():
+	default:
+		t.Error("Expected Done to be closed")
+	}
+	if coordinator.Shutdown() != err || coordinator.Wait() != err {
+		t.Error("Expected further calls to return the result of the first shutdown")
+	}
+}
+
+func TestShutdownCoordinatorTimeouts(t *testing.T) {
+	coordinator := NewShutdownCoordinator(300 * time.Millisecond)
+	exitCode := -1
+	coordinator.ExitFunc = func(code int) { exitCode = code }
+
+	ran := false
+	coordinator.OnShutdown("last", 0, 0, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	coordinator.OnShutdown("stuck", 1, time.Minute, func(ctx context.Context) error {
+		select {}
+	})
+	coordinator.OnShutdown("slow", 2, 50*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	err := coordinator.Shutdown()
+	if !errors.Is(err, ErrShutdownTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a shutdown timeout, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Shutdown took %v despite the global deadline", elapsed)
+	}
+	if exitCode != 1 {
+		t.Errorf("Expected a forced exit with code 1, got %d", exitCode)
+	}
+	if ran {
+		t.Error("Expected the hooks after the deadline to be skipped")
+	}
+}
+
+func TestShutdownCoordinatorReload(t *testing.T) {
+	coordinator := NewShutdownCoordinator(time.Second)
+
+	var calls []string
+	coordinator.OnReload("config", func(ctx context.Context) error {
+		calls = append(calls, "config")
+		return nil
+	})
+	coordinator.OnReload("tls", func(ctx context.Context) error {
+		calls = append(calls, "tls")
+		return errors.New("certificate missing")
+	})
+
+	err := coordinator.Reload()
+	if err == nil || !strings.Contains(err.Error(), "reload tls") {
+		t.Errorf("Expected the error of the tls reload, got: %v", err)
+	}
+	if strings.Join(calls, ",") != "config,tls" {
+		t.Errorf("Unexpected reload calls: %v", calls)
+	}
+	if coordinator.Context().Err() != nil {
+		t.Error("A reload must not cancel the root context")
+	}
+}
+
+func TestShutdownCoordinatorSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Signals cannot be sent on windows")
+	}
+
+	coordinator := NewShutdownCoordinator(time.Second)
+	errs := make(chan error, 1)
+	coordinator.ErrorHandler = func(err error) { errs <- err }
+
+	reloaded := make(chan struct{}, 1)
+	coordinator.OnReload("config", func(ctx context.Context) error {
+		reloaded <- struct{}{}
+		return nil
+	})
+	coordinator.OnShutdown("server", 0, 0, func(ctx context.Context) error {
+		return errors.New("connections left")
+	})
+	coordinator.Listen()
+
+	self, _ := os.FindProcess(os.Getpid())
+
+	self.Signal(syscall.SIGHUP)
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGHUP did not trigger a reload")
+	}
+
+	self.Signal(syscall.SIGTERM)
+	select {
+	case <-coordinator.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGTERM did not trigger the shutdown")
+	}
+	if err := <-errs; !strings.Contains(err.Error(), "connections left") {
+		t.Errorf("Expected the shutdown error to be reported, got: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// The default maximum length of a streamed output line. Longer lines are delivered in
+// several chunks instead of being dropped.
+const DefaultMaxLineLength = 64 * 1024
+
+// Identifies the output stream of a command.
+type OutputStream int
+
+const (
+	StreamStdout OutputStream = iota + 1
+	StreamStderr
+)
+
+// Returns "stdout" or "stderr".
+func (s OutputStream) String() string {
+	switch s {
+	case StreamStdout:
+		return "stdout"
+	case StreamStderr:
+		return "stderr"
+	}
+	return "unknown"
+}
+
+// Holds a single line of output streamed from a running command.
+//
+// Fields:
+//   - Stream: OutputStream - the stream the line was written to
+//   - Text: string - the content of the line without the trailing newline
+//   - Time: time.Time - the moment the line was received
+//   - Partial: bool - true if the line exceeded the maximum line length and continues in the next chunk
+type OutputLine struct {
+	Stream  OutputStream `json:"stream" bson:"stream" yaml:"stream"`
+	Text    string       `json:"text" bson:"text" yaml:"text"`
+	Time    time.Time    `json:"time" bson:"time" yaml:"time"`
+	Partial bool         `json:"partial" bson:"partial" yaml:"partial"`
+}
+
+// Receives output lines while a command is running.
+// Calls are serialized, so a handler does not need to be safe for concurrent use,
+// but it blocks the command's output while it runs.
+type LineHandler func(line OutputLine)
+
+// Delivers every line of stdout and stderr to the handler while the command runs.
+// The output is still captured in the CommandResult.
+func (c *Command) WithLineHandler(handler LineHandler) *Command {
+	c.lineHandler = handler
+	return c
+}
+
+// Sets the maximum length of a streamed line in bytes. Longer lines are split into
+// chunks marked as Partial. Values below 1 restore DefaultMaxLineLength.
+func (c *Command) WithMaxLineLength(length int) *Command {
+	if length < 1 {
+		length = DefaultMaxLineLength
+	}
+	c.maxLineLength = length
+	return c
+}
+
+// Runs the command and sends its output line by line to the channel while it runs.
+// The channel is closed once the command has finished. If the context ends while the
+// consumer is not reading, further lines are dropped so the command can be killed.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of the command
+//   - lines: chan<- OutputLine - receives the output lines, closed when the command finished
+//
+// Returns:
+//   - *CommandResult: the outcome of the execution, nil if the command could not be started
+//   - error: an error if the command could not be started, did not exit successfully or timed out
+//
+// Example usage:
+//
+//	lines := make(chan OutputLine)
+//	go func() {
+//	  for line := range lines {
+//	    fmt.Printf("[%s] %s\n", line.Stream, line.Text)
+//	  }
+//	}()
+//	result, err := NewCommand("make", "deploy").Stream(ctx, lines)
+func (c *Command) Stream(ctx context.Context, lines chan<- OutputLine) (*CommandResult, error) {
+	defer close(lines)
+
+	previous := c.lineHandler
+	defer func() { c.lineHandler = previous }()
+
+	c.lineHandler = func(line OutputLine) {
+		if previous != nil {
+			previous(line)
+		}
+		select {
+		case lines <- line:
+		case <-ctx.Done():
+		}
+	}
+
+	return c.Run(ctx)
+}
+
+// Splits written output into lines and hands them to a LineHandler.
+type lineWriter struct {
+	stream  OutputStream
+	handler LineHandler
+	max     int
+	mu      *sync.Mutex
+	buf     []byte
+}
+
+// Creates a lineWriter. Writers sharing the mutex never call the handler concurrently.
+func newLineWriter(stream OutputStream, handler LineHandler, maxLength int, mu *sync.Mutex) *lineWriter {
+	if maxLength < 1 {
+		maxLength = DefaultMaxLineLength
+	}
+	return &lineWriter{stream: stream, handler: handler, max: maxLength, mu: mu}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.buf = append(w.buf, p...)
+			w.emitChunks()
+			break
+		}
+
+		w.buf = append(w.buf, p[:i]...)
+		p = p[i+1:]
+		w.buf = bytes.TrimSuffix(w.buf, []byte("\r"))
+		w.emitChunks()
+		w.emit(w.buf, false)
+		w.buf = w.buf[:0]
+	}
+	return n, nil
+}
+
+// Delivers the buffered content in chunks of the maximum line length as long as it
+// exceeds that length. A trailing carriage return is not counted, it may still turn
+// out to be part of a CRLF line ending.
+func (w *lineWriter) emitChunks() {
+	for {
+		pending := len(w.buf)
+		if pending > 0 && w.buf[pending-1] == '\r' {
+			pending--
+		}
+		if pending <= w.max {
+			return
+		}
+		w.emit(w.buf[:w.max], true)
+		w.buf = w.buf[w.max:]
+	}
+}
+
+// Delivers a trailing line that was not terminated by a newline.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.emit(w.buf, false)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(text []byte, partial bool) {
+	w.handler(OutputLine{
+		Stream:  w.stream,
+		Text:    string(text),
+		Time:    time.Now(),
+		Partial: partial,
+	})
+}
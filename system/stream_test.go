@@ -0,0 +1,117 @@
+package system
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCommandLineHandler(t *testing.T) {
+	var lines []OutputLine
+	result, err := NewShellCommand("echo one; echo two >&2; printf three").
+		WithLineHandler(func(line OutputLine) {
+			lines = append(lines, line)
+		}).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+
+	got := map[OutputStream][]string{}
+	for _, line := range lines {
+		if line.Time.IsZero() {
+			t.Errorf("Line %q has no timestamp", line.Text)
+		}
+		got[line.Stream] = append(got[line.Stream], line.Text)
+	}
+
+	if strings.Join(got[StreamStdout], ",") != "one,three" {
+		t.Errorf("Unexpected stdout lines: %v", got[StreamStdout])
+	}
+	if strings.Join(got[StreamStderr], ",") != "two" {
+		t.Errorf("Unexpected stderr lines: %v", got[StreamStderr])
+	}
+
+	// Streaming must not affect the captured output
+	if result.StdoutString() != "one\nthree" {
+		t.Errorf("Unexpected captured stdout: %q", result.Stdout)
+	}
+}
+
+func TestCommandStreamDeliversWhileRunning(t *testing.T) {
+	lines := make(chan OutputLine)
+	done := make(chan struct{})
+
+	var first time.Time
+	var count int
+	go func() {
+		defer close(done)
+		for line := range lines {
+			if count == 0 {
+				first = line.Time
+			}
+			count++
+		}
+	}()
+
+	start := time.Now()
+	result, err := NewShellCommand("echo early; sleep 0.3; echo late").Stream(context.Background(), lines)
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	<-done
+
+	if count != 2 {
+		t.Fatalf("Expected 2 lines, got %d", count)
+	}
+	if first.Sub(start) >= result.Duration {
+		t.Errorf("First line was not delivered before the command finished")
+	}
+}
+
+func TestLineWriterMaxLineLength(t *testing.T) {
+	var lines []OutputLine
+	var mu sync.Mutex
+	w := newLineWriter(StreamStdout, func(line OutputLine) {
+		lines = append(lines, line)
+	}, 4, &mu)
+
+	w.Write([]byte("abcdefghij\nab"))
+	w.Write([]byte("cd\r\nxy"))
+	w.Flush()
+
+	expected := []OutputLine{
+		{Text: "abcd", Partial: true},
+		{Text: "efgh", Partial: true},
+		{Text: "ij"},
+		{Text: "abcd"},
+		{Text: "xy"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d: %+v", len(expected), len(lines), lines)
+	}
+	for i, line := range lines {
+		if line.Text != expected[i].Text || line.Partial != expected[i].Partial {
+			t.Errorf("Line %d: expected %q (partial %v), got %q (partial %v)",
+				i, expected[i].Text, expected[i].Partial, line.Text, line.Partial)
+		}
+	}
+}
+
+func TestCommandLongLinesAreNotTruncated(t *testing.T) {
+	var total int
+	result, err := NewCommand("head", "-c", "200000", "/dev/zero").
+		WithLineHandler(func(line OutputLine) {
+			total += len(line.Text)
+		}).
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+
+	if total != 200000 || len(result.Stdout) != 200000 {
+		t.Errorf("Expected 200000 bytes streamed and captured, got %d and %d", total, len(result.Stdout))
+	}
+}
@@ -1,15 +1,17 @@
 package system
 
 import (
-	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os/exec"
-	"runtime"
 )
 
 // Executes the specified command in a shell and returns the output as a byte slice.
-// Any error occurred during command execution or output retrieval is also returned.
+// The output is returned exactly as written by the command, including newlines.
+// Any error occurred during command execution or output retrieval is also returned,
+// a command exiting with a non-zero code is reported as an error as well.
 //
 // Example:
 //
@@ -21,32 +23,41 @@ import (
 //
 // Returns:
 //
-//	[]byte: the output of the command
+//	[]byte: the output of the command, also returned when the command failed after starting
 //	error: an error if occurred during command execution or output retrieval
 func RunCommandGetOutput(command string) ([]byte, error) {
-	cmd := exec.Command("bash", "-c", command)
-
-	// Create a pipe for the command's output
-	cmdReader, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout pipe for command: %w", err)
-	}
+	return RunCommandGetOutputContext(context.Background(), command)
+}
 
-	err = cmd.Start()
+// Executes the specified command in a shell like RunCommandGetOutput, but kills the
+// command and every process it spawned once the context is cancelled or times out.
+// A timeout is reported as an error wrapping ErrCommandTimeout.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	output, err := RunCommandGetOutputContext(ctx, "ls -l")
+//
+// Parameters:
+//
+//	ctx context.Context: controls the lifetime of the command
+//	command string: the command to be executed
+//
+// Returns:
+//
+//	[]byte: the output of the command, also returned when the command failed after starting
+//	error: an error if occurred during command execution, or if the command timed out
+func RunCommandGetOutputContext(ctx context.Context, command string) ([]byte, error) {
+	result, err := NewCommand("bash", "-c", command).execute(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start command: %w", err)
-	}
-
-	scanner := bufio.NewScanner(cmdReader)
-	defer cmd.Wait()
-
-	var result []byte
-
-	for scanner.Scan() {
-		result = append(result, scanner.Bytes()...)
+		if result == nil {
+			return nil, err
+		}
+		return result.Stdout, fmt.Errorf("command failed: %s, error: %w, stderr: %s", command, err, result.Stderr)
 	}
 
-	return result, nil
+	return result.Stdout, nil
 }
 
 // Executes a specified command based on the operating system and returns the output as a string.
@@ -62,34 +73,41 @@ func RunCommandGetOutput(command string) ([]byte, error) {
 //	string: the output of the command execution
 //	error: an error if occurred during command execution
 func RunCommand(cmdLine string) (string, error) {
-	var cmd *exec.Cmd
-
-	// Determine how to run the command based on the operating system
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", cmdLine)
-	} else {
-		cmd = exec.Command("/bin/sh", "-c", cmdLine)
-	}
-
-	// Create buffers to capture stdout and stderr
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	return RunCommandContext(context.Background(), cmdLine)
+}
 
-	// Run the command
-	err := cmd.Run()
+// Executes a command line in the platform shell like RunCommand, but kills the command
+// and every process it spawned once the context is cancelled or times out.
+// A timeout is reported as an error wrapping ErrCommandTimeout.
+//
+// Parameters:
+//
+//	ctx context.Context: controls the lifetime of the command
+//	cmdLine string: the command line to be executed
+//
+// Returns:
+//
+//	string: the output of the command execution
+//	error: an error if occurred during command execution, or if the command timed out
+func RunCommandContext(ctx context.Context, cmdLine string) (string, error) {
+	result, err := NewShellCommand(cmdLine).execute(ctx)
 
 	// If an error occurred, wrap it in a more descriptive error
 	if err != nil {
-		return "", fmt.Errorf("command failed: %s, error: %s, stderr: %s", cmdLine, err, stderr.String())
+		var stderr []byte
+		if result != nil {
+			stderr = result.Stderr
+		}
+		return "", fmt.Errorf("command failed: %s, error: %w, stderr: %s", cmdLine, err, stderr)
 	}
 
 	// Return the stdout output
-	return stdout.String(), nil
+	return result.StdoutString(), nil
 }
 
 // Checks the existence of a specified command on the system.
-// It tries to execute the command with '-v' flag, which normally outputs the command version.
+// It searches the directories named by the PATH environment variable for an executable
+// with the given name, so it works without a shell.
 // If the command is found and is executable, it returns true and nil error.
 // If the command does not exist, it returns false and nil error.
 // If an error occurs during the command check, it returns false and the occurred error.
@@ -112,14 +130,57 @@ func RunCommand(cmdLine string) (string, error) {
 //	bool: true if the command exists, false otherwise
 //	error: an error if occurred during command check
 func CommandExists(command string) (bool, error) {
-	cmd := exec.Command("command", "-v", command)
-	err := cmd.Run()
+	_, err := LookupCommand(command)
 	if err != nil {
-		if _, ok := err.(*exec.ExitError); ok {
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
 			// Command not found
 			return false, nil
 		}
-		return false, fmt.Errorf("failed to run command: %w", err)
+		return false, err
 	}
 	return true, nil
 }
+
+// Resolves a command name to the absolute path of the executable that would be run.
+// Names containing a path separator are checked directly, all others are searched
+// in the directories named by the PATH environment variable.
+//
+// Example:
+//
+//	path, err := LookupCommand("git")
+//
+// Parameters:
+//
+//	command string: the command to be resolved
+//
+// Returns:
+//
+//	string: the absolute path of the executable
+//	error: an error wrapping exec.ErrNotFound if the command does not exist
+func LookupCommand(command string) (string, error) {
+	path, err := DefaultExecutor().LookPath(command)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up command %s: %w", command, err)
+	}
+	return path, nil
+}
+
+// Returns true if the slice contains the value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the slice contains the value.
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
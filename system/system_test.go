@@ -18,7 +18,7 @@ func testRunCommandGetOutput(t *testing.T) {
 		t.Errorf("Failed to run command: %v", err)
 	}
 
-	expected := []byte("Hello, World!")
+	expected := []byte("Hello, World!\n")
 	if string(output) != string(expected) {
 		t.Errorf("Unexpected command output. Expected: %s, Got: %s", expected, output)
 	}
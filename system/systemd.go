@@ -0,0 +1,419 @@
+package system
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The directory holding the unit files of the system manager.
+const DefaultSystemUnitDir = "/etc/systemd/system"
+
+// Describes a service unit file. Free-text values such as the description, paths, users
+// and environment variables are written literally, a "%" in them is escaped.
+//
+// Fields:
+//   - Description: string - the human readable description of the unit
+//   - Documentation: []string - URLs documenting the service
+//   - After: []string - units that have to be started before this one
+//   - Wants: []string - units started together with this one, failures are ignored
+//   - Requires: []string - units started together with this one, failures are fatal
+//   - Type: string - the startup type, e.g. "simple", "notify" or "oneshot", systemd's default if empty
+//   - ExecStartPre: []string - commands run before ExecStart; written verbatim, specifiers
+//     are expanded by systemd
+//   - ExecStart: string - the command starting the service, required; written verbatim,
+//     specifiers are expanded by systemd
+//   - ExecReload: string - the command reloading the service; written verbatim, specifiers
+//     are expanded by systemd
+//   - ExecStop: string - the command stopping the service, a SIGTERM is sent if empty;
+//     written verbatim, specifiers are expanded by systemd
+//   - WorkingDirectory: string - the working directory of the service
+//   - User: string - the user the service runs as
+//   - Group: string - the group the service runs as
+//   - Environment: map[string]string - environment variables of the service
+//   - EnvironmentFile: string - a file to read environment variables from
+//   - Restart: string - when to restart the service, e.g. "on-failure" or "always"
+//   - RestartSec: time.Duration - the delay before a restart
+//   - TimeoutStopSec: time.Duration - how long to wait for the service to stop before killing it
+//   - WatchdogSec: time.Duration - the watchdog timeout, requires the service to call Notify(NotifyWatchdog)
+//   - LimitNOFILE: int - the maximum number of open files
+//   - WantedBy: []string - the targets the unit is added to when enabled, "multi-user.target" if empty
+type UnitSpec struct {
+	Description   string
+	Documentation []string
+	After         []string
+	Wants         []string
+	Requires      []string
+
+	Type             string
+	ExecStartPre     []string
+	ExecStart        string
+	ExecReload       string
+	ExecStop         string
+	WorkingDirectory string
+	User             string
+	Group            string
+	Environment      map[string]string
+	EnvironmentFile  string
+	Restart          string
+	RestartSec       time.Duration
+	TimeoutStopSec   time.Duration
+	WatchdogSec      time.Duration
+	LimitNOFILE      int
+
+	WantedBy []string
+}
+
+// Describes the state of a unit as reported by 'systemctl show'.
+//
+// Fields:
+//   - Name: string - the name of the unit
+//   - Description: string - the description of the unit
+//   - LoadState: string - e.g. "loaded" or "not-found"
+//   - ActiveState: string - e.g. "active", "inactive", "activating" or "failed"
+//   - SubState: string - the unit type specific state, e.g. "running" or "dead"
+//   - UnitFileState: string - e.g. "enabled", "disabled" or "static"
+//   - MainPID: int - the main process of the service, 0 if it is not running
+//   - ExecMainStatus: int - the exit status of the last main process
+//   - NRestarts: int - how often the service was restarted automatically
+type UnitStatus struct {
+	Name           string `json:"name" bson:"name" yaml:"name"`
+	Description    string `json:"description" bson:"description" yaml:"description"`
+	LoadState      string `json:"load_state" bson:"load_state" yaml:"load_state"`
+	ActiveState    string `json:"active_state" bson:"active_state" yaml:"active_state"`
+	SubState       string `json:"sub_state" bson:"sub_state" yaml:"sub_state"`
+	UnitFileState  string `json:"unit_file_state" bson:"unit_file_state" yaml:"unit_file_state"`
+	MainPID        int    `json:"main_pid" bson:"main_pid" yaml:"main_pid"`
+	ExecMainStatus int    `json:"exec_main_status" bson:"exec_main_status" yaml:"exec_main_status"`
+	NRestarts      int    `json:"n_restarts" bson:"n_restarts" yaml:"n_restarts"`
+}
+
+// The properties queried by SystemdManager.Status.
+var unitStatusProperties = []string{
+	"Id", "Description", "LoadState", "ActiveState", "SubState",
+	"UnitFileState", "MainPID", "ExecMainStatus", "NRestarts",
+}
+
+// Returns true if the unit is active, i.e. running or, for oneshot services, exited successfully.
+func (s *UnitStatus) Active() bool {
+	return s.ActiveState == "active" || s.ActiveState == "reloading"
+}
+
+// Returns true if the unit file exists and was loaded by systemd.
+func (s *UnitStatus) Loaded() bool {
+	return s.LoadState == "loaded"
+}
+
+// Manages units through systemctl.
+//
+// Fields:
+//   - Executor: Executor - runs systemctl, the DefaultExecutor if nil
+//   - User: bool - manages the units of the calling user ('systemctl --user') instead of the system
+//   - UnitDir: string - where Install writes unit files, DefaultSystemUnitDir or the user's
+//     systemd directory if empty
+//
+// Example usage:
+//
+//	manager := &SystemdManager{}
+//	err := manager.Install(ctx, "agent.service", UnitSpec{
+//	  Description: "Monitoring agent",
+//	  Type:        "notify",
+//	  ExecStart:   "/usr/local/bin/agent --config /etc/agent.yaml",
+//	  Restart:     "on-failure",
+//	})
+//	if err == nil {
+//	  err = manager.Enable(ctx, "agent.service", true)
+//	}
+type SystemdManager struct {
+	Executor Executor
+	User     bool
+	UnitDir  string
+}
+
+// Renders the spec as the content of a unit file.
+//
+// Returns:
+//   - string: the unit file
+//   - error: if ExecStart is missing or a value contains a line break
+func (s UnitSpec) Render() (string, error) {
+	if s.ExecStart == "" {
+		return "", fmt.Errorf("unit spec has no ExecStart")
+	}
+
+	var b strings.Builder
+	var err error
+	write := func(key, value string) {
+		if value == "" || err != nil {
+			return
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			err = fmt.Errorf("value of %s contains a line break", key)
+			return
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, value)
+	}
+
+	b.WriteString("[Unit]\n")
+	write("Description", escapeSpecifiers(s.Description))
+	write("Documentation", escapeSpecifiers(strings.Join(s.Documentation, " ")))
+	write("After", strings.Join(s.After, " "))
+	write("Wants", strings.Join(s.Wants, " "))
+	write("Requires", strings.Join(s.Requires, " "))
+
+	b.WriteString("\n[Service]\n")
+	write("Type", s.Type)
+	for _, command := range s.ExecStartPre {
+		write("ExecStartPre", command)
+	}
+	write("ExecStart", s.ExecStart)
+	write("ExecReload", s.ExecReload)
+	write("ExecStop", s.ExecStop)
+	write("WorkingDirectory", escapeSpecifiers(s.WorkingDirectory))
+	write("User", escapeSpecifiers(s.User))
+	write("Group", escapeSpecifiers(s.Group))
+
+	keys := make([]string, 0, len(s.Environment))
+	for key := range s.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		write("Environment", quoteUnitValue(key+"="+s.Environment[key]))
+	}
+	write("EnvironmentFile", escapeSpecifiers(s.EnvironmentFile))
+	write("Restart", s.Restart)
+	write("RestartSec", formatUnitDuration(s.RestartSec))
+	write("TimeoutStopSec", formatUnitDuration(s.TimeoutStopSec))
+	write("WatchdogSec", formatUnitDuration(s.WatchdogSec))
+	if s.LimitNOFILE > 0 {
+		write("LimitNOFILE", strconv.Itoa(s.LimitNOFILE))
+	}
+
+	wantedBy := s.WantedBy
+	if len(wantedBy) == 0 {
+		wantedBy = []string{"multi-user.target"}
+	}
+	b.WriteString("\n[Install]\n")
+	write("WantedBy", strings.Join(wantedBy, " "))
+
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Writes the unit file into the unit directory and reloads the manager configuration.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of 'systemctl daemon-reload'
+//   - name: string - the name of the unit, e.g. "agent.service"
+//   - spec: UnitSpec - the unit to install
+//
+// Returns:
+//   - error: if the spec is invalid, the file could not be written or the reload failed
+func (m *SystemdManager) Install(ctx context.Context, name string, spec UnitSpec) error {
+	content, err := spec.Render()
+	if err != nil {
+		return fmt.Errorf("failed to render unit %s: %w", name, err)
+	}
+	if name == "" || strings.ContainsRune(name, '/') {
+		return fmt.Errorf("invalid unit name %q", name)
+	}
+
+	dir, err := m.unitDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create unit directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write unit %s: %w", name, err)
+	}
+	return m.DaemonReload(ctx)
+}
+
+// Starts the units.
+func (m *SystemdManager) Start(ctx context.Context, units ...string) error {
+	return m.run(ctx, append([]string{"start"}, units...)...)
+}
+
+// Stops the units.
+func (m *SystemdManager) Stop(ctx context.Context, units ...string) error {
+	return m.run(ctx, append([]string{"stop"}, units...)...)
+}
+
+// Restarts the units, starting them if they are not running.
+func (m *SystemdManager) Restart(ctx context.Context, units ...string) error {
+	return m.run(ctx, append([]string{"restart"}, units...)...)
+}
+
+// Asks the units to reload their configuration.
+func (m *SystemdManager) Reload(ctx context.Context, units ...string) error {
+	return m.run(ctx, append([]string{"reload"}, units...)...)
+}
+
+// Enables the unit to be started at boot, and starts it right away if now is true.
+func (m *SystemdManager) Enable(ctx context.Context, unit string, now bool) error {
+	if now {
+		return m.run(ctx, "enable", "--now", unit)
+	}
+	return m.run(ctx, "enable", unit)
+}
+
+// Disables the unit, and stops it right away if now is true.
+func (m *SystemdManager) Disable(ctx context.Context, unit string, now bool) error {
+	if now {
+		return m.run(ctx, "disable", "--now", unit)
+	}
+	return m.run(ctx, "disable", unit)
+}
+
+// Reloads the unit files of the manager, required after a unit file was changed.
+func (m *SystemdManager) DaemonReload(ctx context.Context) error {
+	return m.run(ctx, "daemon-reload")
+}
+
+// Queries properties of a unit.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of 'systemctl show'
+//   - unit: string - the unit to query
+//   - properties: ...string - the properties to query, all if omitted
+//
+// Returns:
+//   - map[string]string: the properties by name
+//   - error: if systemctl failed
+//
+// Example usage:
+//
+//	properties, err := manager.Show(ctx, "nginx.service", "MainPID", "MemoryCurrent")
+func (m *SystemdManager) Show(ctx context.Context, unit string, properties ...string) (map[string]string, error) {
+	args := []string{"show", unit}
+	if len(properties) > 0 {
+		args = append(args, "--property="+strings.Join(properties, ","))
+	}
+	result, err := m.command(args...).Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseUnitProperties(result.StdoutString()), nil
+}
+
+// Returns the state of the unit. Unknown units are reported with LoadState "not-found"
+// rather than an error, like systemctl does.
+func (m *SystemdManager) Status(ctx context.Context, unit string) (*UnitStatus, error) {
+	properties, err := m.Show(ctx, unit, unitStatusProperties...)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &UnitStatus{
+		Name:          properties["Id"],
+		Description:   properties["Description"],
+		LoadState:     properties["LoadState"],
+		ActiveState:   properties["ActiveState"],
+		SubState:      properties["SubState"],
+		UnitFileState: properties["UnitFileState"],
+	}
+	if status.Name == "" {
+		status.Name = unit
+	}
+	status.MainPID, _ = strconv.Atoi(properties["MainPID"])
+	status.ExecMainStatus, _ = strconv.Atoi(properties["ExecMainStatus"])
+	status.NRestarts, _ = strconv.Atoi(properties["NRestarts"])
+	return status, nil
+}
+
+// Returns true if the unit is active.
+func (m *SystemdManager) IsActive(ctx context.Context, unit string) (bool, error) {
+	status, err := m.Status(ctx, unit)
+	if err != nil {
+		return false, err
+	}
+	return status.Active(), nil
+}
+
+// Parses the KEY=VALUE output of 'systemctl show'. Values keep everything after the first
+// '=', empty values are included, lines without '=' are ignored.
+//
+// Parameters:
+//   - output: string - the output of 'systemctl show'
+//
+// Returns:
+//   - map[string]string: the properties by name
+func ParseUnitProperties(output string) map[string]string {
+	properties := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || key == "" {
+			continue
+		}
+		properties[key] = value
+	}
+	return properties
+}
+
+// Runs systemctl with the given arguments.
+func (m *SystemdManager) run(ctx context.Context, args ...string) error {
+	_, err := m.command(args...).Run(ctx)
+	return err
+}
+
+// Creates the systemctl command, adding --user for user managers.
+func (m *SystemdManager) command(args ...string) *Command {
+	if m.User {
+		args = append([]string{"--user"}, args...)
+	}
+	return NewCommand("systemctl", args...).WithExecutor(m.Executor)
+}
+
+// Returns the directory Install writes unit files to.
+func (m *SystemdManager) unitDir() (string, error) {
+	if m.UnitDir != "" {
+		return m.UnitDir, nil
+	}
+	if !m.User {
+		return DefaultSystemUnitDir, nil
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user unit directory: %w", err)
+	}
+	return filepath.Join(config, "systemd", "user"), nil
+}
+
+// Escapes the specifier character "%" of an Environment assignment and quotes it if it
+// contains whitespace, quotes or backslashes.
+func quoteUnitValue(value string) string {
+	value = escapeSpecifiers(value)
+	if !strings.ContainsAny(value, " \t\"'\\") {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// Escapes the specifier character "%", so systemd reads the value literally.
+func escapeSpecifiers(value string) string {
+	return strings.ReplaceAll(value, "%", "%%")
+}
+
+// Formats a duration the way unit files expect it, empty for zero.
+func formatUnitDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return ""
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	default:
+		return strconv.FormatInt(int64(d/time.Millisecond), 10) + "ms"
+	}
+}
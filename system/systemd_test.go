@@ -0,0 +1,153 @@
+package system
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnitSpecRender(t *testing.T) {
+	spec := UnitSpec{
+		Description:    "Monitoring agent, 100% uptime",
+		After:          []string{"network-online.target"},
+		Wants:          []string{"network-online.target"},
+		Type:           "notify",
+		ExecStartPre:   []string{"/usr/local/bin/agent check"},
+		ExecStart:      "/usr/local/bin/agent --config %h/agent.yaml",
+		User:           "agent",
+		Environment:    map[string]string{"LOG_LEVEL": "debug", "GREETING": `say "hi" 100%`, "DB_URL": "postgres://agent:p%40ss@db/app"},
+		Restart:        "on-failure",
+		RestartSec:     5 * time.Second,
+		TimeoutStopSec: 1500 * time.Millisecond,
+		LimitNOFILE:    65536,
+	}
+
+	content, err := spec.Render()
+	if err != nil {
+		t.Fatalf("Failed to render unit: %v", err)
+	}
+	expected := `[Unit]
+Description=Monitoring agent, 100%% uptime
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=notify
+ExecStartPre=/usr/local/bin/agent check
+ExecStart=/usr/local/bin/agent --config %h/agent.yaml
+User=agent
+Environment=DB_URL=postgres://agent:p%%40ss@db/app
+Environment="GREETING=say \"hi\" 100%%"
+Environment=LOG_LEVEL=debug
+Restart=on-failure
+RestartSec=5s
+TimeoutStopSec=1500ms
+LimitNOFILE=65536
+
+[Install]
+WantedBy=multi-user.target
+`
+	if content != expected {
+		t.Errorf("Unexpected unit file. Expected:\n%s\nGot:\n%s", expected, content)
+	}
+
+	if _, err := (UnitSpec{Description: "no command"}).Render(); err == nil {
+		t.Error("Expected an error for a spec without ExecStart")
+	}
+	if _, err := (UnitSpec{ExecStart: "/bin/true", Description: "two\nlines"}).Render(); err == nil {
+		t.Error("Expected an error for a value with a line break")
+	}
+}
+
+func TestSystemdManager(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On(`^systemctl --user show agent.service --property=`).Stdout(
+		"Id=agent.service\nDescription=Monitoring agent\nLoadState=loaded\nActiveState=active\n" +
+			"SubState=running\nUnitFileState=enabled\nMainPID=4242\nExecMainStatus=0\nNRestarts=2\n")
+	fake.On(`^systemctl --user show missing.service`).Stdout(
+		"Id=missing.service\nLoadState=not-found\nActiveState=inactive\nSubState=dead\nMainPID=0\n")
+	fake.On(`^systemctl --user stop broken.service$`).ExitCode(5).Stderr("Unit broken.service not loaded.\n")
+	fake.On(`^systemctl --user `)
+
+	manager := &SystemdManager{Executor: fake, User: true, UnitDir: t.TempDir()}
+	ctx := context.Background()
+
+	status, err := manager.Status(ctx, "agent.service")
+	if err != nil {
+		t.Fatalf("Failed to query status: %v", err)
+	}
+	expected := UnitStatus{
+		Name: "agent.service", Description: "Monitoring agent", LoadState: "loaded", ActiveState: "active",
+		SubState: "running", UnitFileState: "enabled", MainPID: 4242, NRestarts: 2,
+	}
+	if *status != expected || !status.Active() || !status.Loaded() {
+		t.Errorf("Unexpected status. Expected: %+v, Got: %+v", expected, *status)
+	}
+
+	if active, err := manager.IsActive(ctx, "missing.service"); err != nil || active {
+		t.Errorf("Expected an unknown unit to be inactive, got %v (%v)", active, err)
+	}
+
+	if err := manager.Stop(ctx, "broken.service"); err == nil || !strings.Contains(err.Error(), "systemctl --user stop broken.service") {
+		t.Errorf("Expected the systemctl failure, got: %v", err)
+	}
+
+	manager.Start(ctx, "a.service", "b.service")
+	manager.Restart(ctx, "agent.service")
+	manager.Reload(ctx, "agent.service")
+	manager.Enable(ctx, "agent.service", true)
+	manager.Disable(ctx, "agent.service", false)
+
+	var lines []string
+	for _, call := range fake.Calls()[3:] {
+		lines = append(lines, call.Line)
+	}
+	expectedLines := []string{
+		"systemctl --user start a.service b.service",
+		"systemctl --user restart agent.service",
+		"systemctl --user reload agent.service",
+		"systemctl --user enable --now agent.service",
+		"systemctl --user disable agent.service",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expectedLines, "\n") {
+		t.Errorf("Unexpected commands. Expected:\n%s\nGot:\n%s", strings.Join(expectedLines, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestSystemdManagerInstall(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On(`^systemctl daemon-reload$`)
+	dir := filepath.Join(t.TempDir(), "units")
+	manager := &SystemdManager{Executor: fake, UnitDir: dir}
+
+	if err := manager.Install(context.Background(), "agent.service", UnitSpec{ExecStart: "/usr/local/bin/agent"}); err != nil {
+		t.Fatalf("Failed to install unit: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "agent.service"))
+	if err != nil || !strings.Contains(string(content), "ExecStart=/usr/local/bin/agent\n") {
+		t.Errorf("Unexpected unit file: %q (%v)", content, err)
+	}
+	if calls := fake.Calls(); len(calls) != 1 {
+		t.Errorf("Expected a daemon-reload, got %d calls", len(calls))
+	}
+
+	if err := manager.Install(context.Background(), "../agent.service", UnitSpec{ExecStart: "/bin/true"}); err == nil {
+		t.Error("Expected an error for a unit name with a path")
+	}
+}
+
+func TestParseUnitProperties(t *testing.T) {
+	properties := ParseUnitProperties("Id=nginx.service\nExecStart={ path=/usr/sbin/nginx ; argv[]=/usr/sbin/nginx -g daemon=on }\nStatusText=\ngarbage\n")
+	if len(properties) != 3 {
+		t.Errorf("Expected 3 properties, got %d: %v", len(properties), properties)
+	}
+	if properties["ExecStart"] != "{ path=/usr/sbin/nginx ; argv[]=/usr/sbin/nginx -g daemon=on }" {
+		t.Errorf("Expected the value to keep further '=', got %q", properties["ExecStart"])
+	}
+	if value, ok := properties["StatusText"]; !ok || value != "" {
+		t.Errorf("Expected an empty StatusText, got %q (%v)", value, ok)
+	}
+}
@@ -0,0 +1,82 @@
+package system
+
+import (
+	"context"
+	"time"
+)
+
+// The grace period of Terminate if the options do not set one.
+const DefaultTerminateGracePeriod = 10 * time.Second
+
+// How long Terminate waits for processes to disappear after SIGKILL.
+const terminateKillTimeout = 5 * time.Second
+
+// Configures how Terminate stops a process.
+//
+// Fields:
+//   - GracePeriod: time.Duration - how long to wait after SIGTERM before sending SIGKILL,
+//     DefaultTerminateGracePeriod if 0
+//   - PollInterval: time.Duration - how often to check whether the processes exited, 50ms if 0
+//   - ProcessGroup: bool - treat the pid as a process group id and signal every member of the group
+//   - Tree: bool - also terminate every descendant of the process, discovered from /proc
+type TerminateOptions struct {
+	GracePeriod  time.Duration
+	PollInterval time.Duration
+	ProcessGroup bool
+	Tree         bool
+}
+
+// Holds the outcome of Terminate.
+//
+// Fields:
+//   - PID: int - the process or process group that was terminated
+//   - LastSignal: string - the last signal sent, "terminated" or "killed"; empty if the
+//     processes had already exited. A process may still have ended on SIGTERM shortly
+//     before SIGKILL was sent.
+//   - Processes: []int - the pids that were signalled, including descendants for a tree
+//   - Duration: time.Duration - the time it took until all processes had exited
+type TerminateResult struct {
+	PID        int           `json:"pid" bson:"pid" yaml:"pid"`
+	LastSignal string        `json:"last_signal" bson:"last_signal" yaml:"last_signal"`
+	Processes  []int         `json:"processes" bson:"processes" yaml:"processes"`
+	Duration   time.Duration `json:"duration" bson:"duration" yaml:"duration"`
+}
+
+// Returns true if the grace period expired and SIGKILL was sent.
+func (r *TerminateResult) Killed() bool {
+	return r.LastSignal == "killed"
+}
+
+// Stops a process gracefully: sends SIGTERM, waits for the grace period while polling
+// whether the process exited, and sends SIGKILL if it is still running afterwards.
+// Zombie processes count as exited. If the context ends during the grace period, SIGKILL
+// is sent right away. Only supported on Unix-based systems.
+//
+// Processes started by this process remain zombies until they are waited for, e.g. by
+// exec.Cmd.Wait, which can be called concurrently.
+//
+// Parameters:
+//   - ctx: context.Context - ends the grace period early
+//   - pid: int - the process, or the process group if options.ProcessGroup is set
+//   - options: TerminateOptions - the grace period and which processes to include
+//
+// Returns:
+//   - *TerminateResult: the signalled processes and the last signal sent
+//   - error: wrapping os.ErrProcessDone if no such process exists, or if the processes
+//     could not be signalled or survived SIGKILL
+//
+// Example usage:
+//
+//	result, err := Terminate(ctx, worker.PID, TerminateOptions{GracePeriod: 30 * time.Second, Tree: true})
+//	if err == nil && result.Killed() {
+//	  log.Printf("worker %d ignored SIGTERM", worker.PID)
+//	}
+func Terminate(ctx context.Context, pid int, options TerminateOptions) (*TerminateResult, error) {
+	if options.GracePeriod <= 0 {
+		options.GracePeriod = DefaultTerminateGracePeriod
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = 50 * time.Millisecond
+	}
+	return terminate(ctx, pid, options)
+}
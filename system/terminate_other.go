@@ -0,0 +1,14 @@
+//go:build !unix
+
+package system
+
+import (
+	"context"
+	"errors"
+)
+
+var errTerminateUnsupported = errors.New("graceful termination is only supported on unix")
+
+func terminate(ctx context.Context, pid int, options TerminateOptions) (*TerminateResult, error) {
+	return nil, errTerminateUnsupported
+}
@@ -0,0 +1,121 @@
+//go:build linux
+
+package system
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Starts the shell script in its own process group and reaps it in the background.
+func startScript(t *testing.T, script string) *exec.Cmd {
+	t.Helper()
+
+	cmd := exec.Command("sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start script: %v", err)
+	}
+	go cmd.Wait()
+	t.Cleanup(func() { syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) })
+
+	// Give the shell time to install traps and start its children
+	time.Sleep(200 * time.Millisecond)
+	return cmd
+}
+
+func TestTerminate(t *testing.T) {
+	cmd := startScript(t, "exec sleep 30")
+
+	result, err := Terminate(context.Background(), cmd.Process.Pid, TerminateOptions{GracePeriod: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Failed to terminate process: %v", err)
+	}
+	if result.LastSignal != "terminated" || result.Killed() || result.Duration > 4*time.Second {
+		t.Errorf("Expected the process to end on SIGTERM, got %+v", result)
+	}
+
+	_, err = Terminate(context.Background(), cmd.Process.Pid, TerminateOptions{})
+	if !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("Expected os.ErrProcessDone for an exited process, got: %v", err)
+	}
+}
+
+func TestTerminateEscalation(t *testing.T) {
+	cmd := startScript(t, `trap "" TERM; sleep 30 & wait`)
+
+	// The background sleep inherits the ignored SIGTERM
+	result, err := Terminate(context.Background(), cmd.Process.Pid, TerminateOptions{GracePeriod: 300 * time.Millisecond, Tree: true})
+	if err != nil {
+		t.Fatalf("Failed to terminate process: %v", err)
+	}
+	if !result.Killed() || len(result.Processes) != 2 {
+		t.Errorf("Expected the process and its child to be killed, got %+v", result)
+	}
+	for _, pid := range result.Processes {
+		if processAlive(pid) {
+			t.Errorf("Process %d survived", pid)
+		}
+	}
+}
+
+func TestTerminateProcessGroup(t *testing.T) {
+	cmd := startScript(t, "sleep 30 & sleep 30 & wait")
+
+	result, err := Terminate(context.Background(), cmd.Process.Pid, TerminateOptions{ProcessGroup: true, GracePeriod: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Failed to terminate process group: %v", err)
+	}
+	if result.LastSignal != "terminated" || len(result.Processes) != 3 {
+		t.Errorf("Expected the group of 3 processes to end on SIGTERM, got %+v", result)
+	}
+
+	members, err := ProcessTable{}.Find(ProcessFilter{Pattern: "^sleep 30$"})
+	if err != nil {
+		t.Fatalf("Failed to list processes: %v", err)
+	}
+	for _, member := range members {
+		if member.PGID == cmd.Process.Pid && member.State != "Z" {
+			t.Errorf("Group member %d survived", member.PID)
+		}
+	}
+}
+
+func TestTerminateContext(t *testing.T) {
+	cmd := startScript(t, `trap "" TERM; while :; do sleep 1; done`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	result, err := Terminate(ctx, cmd.Process.Pid, TerminateOptions{GracePeriod: time.Minute})
+	if err != nil {
+		t.Fatalf("Failed to terminate process: %v", err)
+	}
+	if !result.Killed() || result.PID != cmd.Process.Pid || result.Duration > 10*time.Second {
+		t.Errorf("Expected SIGKILL once the context ended, got %+v", result)
+	}
+}
+
+func TestRunningTargetsSkipsReusedPids(t *testing.T) {
+	self := os.Getpid()
+	started, ok := processStart(self)
+	if !ok {
+		t.Fatal("Failed to read the start time of the current process")
+	}
+
+	if running := runningTargets([]int{self}, map[int]time.Duration{self: started}); len(running) != 1 {
+		t.Errorf("Expected the unchanged process to be running, got %v", running)
+	}
+	// A different start time means the pid now belongs to another process
+	if running := runningTargets([]int{self}, map[int]time.Duration{self: started + time.Second}); len(running) != 0 {
+		t.Errorf("Expected a reused pid to be skipped, got %v", running)
+	}
+	if running := runningTargets([]int{self}, map[int]time.Duration{}); len(running) != 1 {
+		t.Errorf("Expected a process without start time to be running, got %v", running)
+	}
+}
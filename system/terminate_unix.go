@@ -0,0 +1,224 @@
+//go:build unix
+
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+func terminate(ctx context.Context, pid int, options TerminateOptions) (*TerminateResult, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("invalid pid %d", pid)
+	}
+
+	result := &TerminateResult{PID: pid}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	if !targetAlive(pid, options.ProcessGroup) {
+		return result, fmt.Errorf("process %d: %w", pid, os.ErrProcessDone)
+	}
+
+	pids := terminateTargets(pid, options)
+	// The start times tell the targets apart from processes reusing their pids later
+	starts := processStarts(pids)
+	result.Processes = pids
+	if err := signalTargets(pid, pids, options.ProcessGroup, syscall.SIGTERM); err != nil {
+		return result, err
+	}
+
+	alive := func() bool {
+		if options.ProcessGroup && targetAlive(pid, true) {
+			return true
+		}
+		return len(runningTargets(pids, starts)) > 0
+	}
+
+	if waitForExit(ctx, options.GracePeriod, options.PollInterval, alive) {
+		result.LastSignal = syscall.SIGTERM.String()
+		return result, nil
+	}
+
+	// Descendants forked during the grace period are killed as well
+	if options.Tree {
+		for _, target := range terminateTargets(pid, options) {
+			if !containsInt(pids, target) {
+				pids = append(pids, target)
+				if started, ok := processStart(target); ok {
+					starts[target] = started
+				}
+			}
+		}
+		result.Processes = pids
+	}
+	if err := signalTargets(pid, runningTargets(pids, starts), options.ProcessGroup, syscall.SIGKILL); err != nil {
+		return result, err
+	}
+	result.LastSignal = syscall.SIGKILL.String()
+
+	if !waitForExit(context.Background(), terminateKillTimeout, options.PollInterval, alive) {
+		return result, fmt.Errorf("process %d did not exit within %s after SIGKILL", pid, terminateKillTimeout)
+	}
+	return result, nil
+}
+
+// Returns the pids to signal: the process itself, or the members of the group, and
+// all their descendants if requested.
+func terminateTargets(pid int, options TerminateOptions) []int {
+	var pids []int
+	if !options.ProcessGroup {
+		pids = append(pids, pid)
+	}
+	if !options.Tree && !options.ProcessGroup {
+		return pids
+	}
+
+	processes, err := ProcessTable{}.List()
+	if err != nil {
+		return pids
+	}
+
+	roots := []int{pid}
+	if options.ProcessGroup {
+		roots = nil
+		for _, process := range processes {
+			if process.PGID == pid {
+				roots = append(roots, process.PID)
+				pids = append(pids, process.PID)
+			}
+		}
+	}
+	if options.Tree {
+		for _, root := range roots {
+			for descendant := range descendantsOf(processes, root) {
+				if !containsInt(pids, descendant) {
+					pids = append(pids, descendant)
+				}
+			}
+		}
+	}
+	return pids
+}
+
+// Sends the signal to the process or group and to every further target.
+// Targets that exited in the meantime are ignored.
+func signalTargets(pid int, pids []int, group bool, signal syscall.Signal) error {
+	if group {
+		if err := syscall.Kill(-pid, signal); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to send %s to process group %d: %w", signal, pid, err)
+		}
+	}
+
+	for _, target := range pids {
+		if err := syscall.Kill(target, signal); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to send %s to process %d: %w", signal, target, err)
+		}
+	}
+	return nil
+}
+
+// Polls until alive reports false. Returns false if the timeout expired or the context ended first.
+func waitForExit(ctx context.Context, timeout, interval time.Duration, alive func() bool) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for alive() {
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return !alive()
+		case <-ctx.Done():
+			return !alive()
+		}
+	}
+	return true
+}
+
+// Returns true if the process, or any member of the process group, is still running.
+func targetAlive(pid int, group bool) bool {
+	if !group {
+		return processAlive(pid)
+	}
+
+	if err := syscall.Kill(-pid, 0); err == syscall.ESRCH {
+		return false
+	}
+	processes, err := ProcessTable{}.List()
+	if err != nil {
+		// Without /proc zombies cannot be told apart
+		return true
+	}
+	for _, process := range processes {
+		if process.PGID == pid && process.State != "Z" {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the start times of the processes, as far as /proc tells them.
+func processStarts(pids []int) map[int]time.Duration {
+	starts := make(map[int]time.Duration, len(pids))
+	for _, pid := range pids {
+		if started, ok := processStart(pid); ok {
+			starts[pid] = started
+		}
+	}
+	return starts
+}
+
+// Returns the time the process started after boot, false if it does not exist or /proc
+// is not available.
+func processStart(pid int) (time.Duration, bool) {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+	_, started, err := parseProcessStat(string(stat))
+	return started, err == nil
+}
+
+// Returns the targets that are still running. A target whose start time was recorded is
+// only included while its pid still belongs to the same process.
+func runningTargets(pids []int, starts map[int]time.Duration) []int {
+	var running []int
+	for _, pid := range pids {
+		if !processAlive(pid) {
+			continue
+		}
+		if started, recorded := starts[pid]; recorded {
+			if current, ok := processStart(pid); !ok || current != started {
+				continue
+			}
+		}
+		running = append(running, pid)
+	}
+	return running
+}
+
+// Returns true if the process exists and is not a zombie.
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err == syscall.ESRCH {
+		return false
+	}
+
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if os.IsNotExist(err) {
+		// Gone in the meantime, unless there is no /proc to tell zombies apart
+		_, procErr := os.Stat("/proc/self/stat")
+		return procErr != nil
+	}
+	if err != nil {
+		return true
+	}
+	process, _, err := parseProcessStat(string(stat))
+	return err != nil || process.State != "Z"
+}
@@ -0,0 +1,429 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The interval of a UsageSampler that does not set one.
+const DefaultSampleInterval = time.Second
+
+// Measures the utilization of the host from successive readings of /proc/stat and
+// /proc/pressure. Only meaningful on Linux.
+//
+// Every sample is compared to the previous one, so the percentages and rates describe
+// the time in between. Smoothing blends every new value with the previous smoothed one
+// (an exponential moving average), which hides short spikes in health checks.
+//
+// Fields:
+//   - Root: string - the directory containing proc, "/" if empty
+//   - Interval: time.Duration - the time between snapshots sent by Run, DefaultSampleInterval if 0
+//   - Smoothing: float64 - the weight of the previous value between 0 (no smoothing) and 1
+//
+// Example usage:
+//
+//	sampler := &UsageSampler{Interval: 5 * time.Second, Smoothing: 0.5}
+//	snapshots := make(chan UsageSnapshot)
+//	go sampler.Run(ctx, snapshots)
+//	for snapshot := range snapshots {
+//	  fmt.Printf("cpu %.1f%%, %.0f context switches/s\n", snapshot.CPU.Busy, snapshot.ContextSwitches)
+//	}
+type UsageSampler struct {
+	Root      string
+	Interval  time.Duration
+	Smoothing float64
+
+	mu       sync.Mutex
+	previous *statReading
+	smoothed *UsageSnapshot
+}
+
+// Holds the share of time a processor spent in each state, in percent.
+//
+// Fields:
+//   - Name: string - the processor, "cpu" for the total of all processors, "cpu0" for the first one
+//   - User: float64 - running user space code, including niced processes
+//   - System: float64 - running kernel code, including interrupt handling
+//   - Idle: float64 - idle
+//   - IOWait: float64 - idle while waiting for I/O
+//   - Steal: float64 - waiting for the hypervisor to schedule the virtual processor
+//   - Busy: float64 - everything but Idle and IOWait
+type CPUUsage struct {
+	Name   string  `json:"name" bson:"name" yaml:"name"`
+	User   float64 `json:"user" bson:"user" yaml:"user"`
+	System float64 `json:"system" bson:"system" yaml:"system"`
+	Idle   float64 `json:"idle" bson:"idle" yaml:"idle"`
+	IOWait float64 `json:"iowait" bson:"iowait" yaml:"iowait"`
+	Steal  float64 `json:"steal" bson:"steal" yaml:"steal"`
+	Busy   float64 `json:"busy" bson:"busy" yaml:"busy"`
+}
+
+// Holds one line of a pressure stall information file.
+//
+// Fields:
+//   - Avg10: float64 - the percentage of time stalled over the last 10 seconds
+//   - Avg60: float64 - the percentage of time stalled over the last 60 seconds
+//   - Avg300: float64 - the percentage of time stalled over the last 300 seconds
+//   - Total: time.Duration - the total stall time since boot
+type PressureStats struct {
+	Avg10  float64       `json:"avg10" bson:"avg10" yaml:"avg10"`
+	Avg60  float64       `json:"avg60" bson:"avg60" yaml:"avg60"`
+	Avg300 float64       `json:"avg300" bson:"avg300" yaml:"avg300"`
+	Total  time.Duration `json:"total" bson:"total" yaml:"total"`
+}
+
+// Holds the pressure stall information of a resource.
+//
+// Fields:
+//   - Some: PressureStats - the time at least one task was stalled on the resource
+//   - Full: PressureStats - the time all non-idle tasks were stalled at once
+type Pressure struct {
+	Some PressureStats `json:"some" bson:"some" yaml:"some"`
+	Full PressureStats `json:"full" bson:"full" yaml:"full"`
+}
+
+// Holds the utilization of the host between two samples.
+//
+// Fields:
+//   - Time: time.Time - the moment the sample was taken
+//   - Elapsed: time.Duration - the time covered by the sample, the uptime for the first one
+//   - CPU: CPUUsage - the utilization of all processors together
+//   - Cores: []CPUUsage - the utilization of every online processor
+//   - ContextSwitches: float64 - the context switches per second
+//   - Interrupts: float64 - the interrupts per second
+//   - Forks: float64 - the processes and threads created per second
+//   - ProcsRunning: int - the number of runnable threads at the moment of the sample
+//   - ProcsBlocked: int - the number of threads blocked on I/O at the moment of the sample
+//   - CPUPressure: *Pressure - the CPU pressure, nil if the kernel does not provide it
+//   - MemoryPressure: *Pressure - the memory pressure, nil if the kernel does not provide it
+//   - IOPressure: *Pressure - the I/O pressure, nil if the kernel does not provide it
+type UsageSnapshot struct {
+	Time            time.Time     `json:"time" bson:"time" yaml:"time"`
+	Elapsed         time.Duration `json:"elapsed" bson:"elapsed" yaml:"elapsed"`
+	CPU             CPUUsage      `json:"cpu" bson:"cpu" yaml:"cpu"`
+	Cores           []CPUUsage    `json:"cores" bson:"cores" yaml:"cores"`
+	ContextSwitches float64       `json:"context_switches" bson:"context_switches" yaml:"context_switches"`
+	Interrupts      float64       `json:"interrupts" bson:"interrupts" yaml:"interrupts"`
+	Forks           float64       `json:"forks" bson:"forks" yaml:"forks"`
+	ProcsRunning    int           `json:"procs_running" bson:"procs_running" yaml:"procs_running"`
+	ProcsBlocked    int           `json:"procs_blocked" bson:"procs_blocked" yaml:"procs_blocked"`
+	CPUPressure     *Pressure     `json:"cpu_pressure" bson:"cpu_pressure" yaml:"cpu_pressure"`
+	MemoryPressure  *Pressure     `json:"memory_pressure" bson:"memory_pressure" yaml:"memory_pressure"`
+	IOPressure      *Pressure     `json:"io_pressure" bson:"io_pressure" yaml:"io_pressure"`
+}
+
+// The raw counters of a /proc/stat reading.
+type statReading struct {
+	uptime          time.Duration
+	cpus            []cpuTimes
+	contextSwitches uint64
+	interrupts      uint64
+	forks           uint64
+	procsRunning    int
+	procsBlocked    int
+}
+
+// The time a processor spent in each state, in clock ticks.
+type cpuTimes struct {
+	name                                                  string
+	user, nice, system, idle, iowait, irq, softirq, steal uint64
+}
+
+// Takes a sample and computes the utilization since the previous one. The first sample
+// of a sampler covers the time since the host booted.
+//
+// Returns:
+//   - *UsageSnapshot: the utilization, smoothed if Smoothing is set
+//   - error: if /proc/stat or /proc/uptime could not be read
+func (s *UsageSampler) Sample() (*UsageSnapshot, error) {
+	reading, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.previous
+	if previous == nil {
+		previous = &statReading{}
+	}
+	s.previous = reading
+
+	snapshot := &UsageSnapshot{
+		Time:         time.Now(),
+		Elapsed:      reading.uptime - previous.uptime,
+		ProcsRunning: reading.procsRunning,
+		ProcsBlocked: reading.procsBlocked,
+	}
+
+	if seconds := snapshot.Elapsed.Seconds(); seconds > 0 {
+		snapshot.ContextSwitches = float64(counterDelta(reading.contextSwitches, previous.contextSwitches)) / seconds
+		snapshot.Interrupts = float64(counterDelta(reading.interrupts, previous.interrupts)) / seconds
+		snapshot.Forks = float64(counterDelta(reading.forks, previous.forks)) / seconds
+	}
+
+	for _, current := range reading.cpus {
+		before := cpuTimes{name: current.name}
+		for _, candidate := range previous.cpus {
+			if candidate.name == current.name {
+				before = candidate
+				break
+			}
+		}
+
+		usage := current.usageSince(before)
+		if usage.Name == "cpu" {
+			snapshot.CPU = usage
+		} else {
+			snapshot.Cores = append(snapshot.Cores, usage)
+		}
+	}
+
+	snapshot.CPUPressure = s.readPressure("cpu")
+	snapshot.MemoryPressure = s.readPressure("memory")
+	snapshot.IOPressure = s.readPressure("io")
+
+	s.smooth(snapshot)
+	return snapshot, nil
+}
+
+// Sends a snapshot to the channel every interval until the context ends. The first
+// snapshot is sent after one interval, covering that interval. The channel is closed
+// once sampling stopped.
+//
+// Parameters:
+//   - ctx: context.Context - controls how long to sample
+//   - snapshots: chan<- UsageSnapshot - receives the snapshots, closed when sampling stopped
+//
+// Returns:
+//   - error: the error of the first sample that failed, nil if the context ended
+func (s *UsageSampler) Run(ctx context.Context, snapshots chan<- UsageSnapshot) error {
+	defer close(snapshots)
+
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+
+	// The baseline, so the first snapshot does not average since boot. It must not be
+	// blended into the following snapshots either.
+	if _, err := s.Sample(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.smoothed = nil
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		snapshot, err := s.Sample()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case snapshots <- *snapshot:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Blends the snapshot with the previous smoothed one and remembers a copy of the result,
+// so callers modifying the returned snapshot do not affect the following ones.
+// Must be called with the mutex held.
+func (s *UsageSampler) smooth(snapshot *UsageSnapshot) {
+	if weight := s.Smoothing; weight > 0 && weight < 1 && s.smoothed != nil {
+		blendUsage(snapshot, s.smoothed, weight)
+	}
+
+	smoothed := *snapshot
+	smoothed.Cores = append([]CPUUsage(nil), snapshot.Cores...)
+	s.smoothed = &smoothed
+}
+
+// Replaces the rates of the snapshot with their exponential moving average.
+func blendUsage(snapshot *UsageSnapshot, previous *UsageSnapshot, weight float64) {
+	blend := func(current *float64, previous float64) {
+		*current = weight*previous + (1-weight)*(*current)
+	}
+	blendCPU := func(current *CPUUsage, previous CPUUsage) {
+		blend(&current.User, previous.User)
+		blend(&current.System, previous.System)
+		blend(&current.Idle, previous.Idle)
+		blend(&current.IOWait, previous.IOWait)
+		blend(&current.Steal, previous.Steal)
+		blend(&current.Busy, previous.Busy)
+	}
+
+	blendCPU(&snapshot.CPU, previous.CPU)
+	for i := range snapshot.Cores {
+		for _, core := range previous.Cores {
+			if core.Name == snapshot.Cores[i].Name {
+				blendCPU(&snapshot.Cores[i], core)
+				break
+			}
+		}
+	}
+	blend(&snapshot.ContextSwitches, previous.ContextSwitches)
+	blend(&snapshot.Interrupts, previous.Interrupts)
+	blend(&snapshot.Forks, previous.Forks)
+}
+
+// Reads the counters of /proc/stat and the uptime they belong to.
+func (s *UsageSampler) read() (*statReading, error) {
+	collector := HostInfoCollector{Root: s.Root}
+	uptime, err := collector.Uptime()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(rootPath(s.Root, "proc/stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	reading := &statReading{uptime: uptime}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(fields[0], "cpu"):
+			times := cpuTimes{name: fields[0]}
+			values := []*uint64{&times.user, &times.nice, &times.system, &times.idle,
+				&times.iowait, &times.irq, &times.softirq, &times.steal}
+			for i, value := range values {
+				if i+1 < len(fields) {
+					*value, _ = strconv.ParseUint(fields[i+1], 10, 64)
+				}
+			}
+			reading.cpus = append(reading.cpus, times)
+		case fields[0] == "ctxt":
+			reading.contextSwitches, _ = strconv.ParseUint(fields[1], 10, 64)
+		case fields[0] == "intr":
+			reading.interrupts, _ = strconv.ParseUint(fields[1], 10, 64)
+		case fields[0] == "processes":
+			reading.forks, _ = strconv.ParseUint(fields[1], 10, 64)
+		case fields[0] == "procs_running":
+			reading.procsRunning, _ = strconv.Atoi(fields[1])
+		case fields[0] == "procs_blocked":
+			reading.procsBlocked, _ = strconv.Atoi(fields[1])
+		}
+	}
+
+	if len(reading.cpus) == 0 {
+		return nil, fmt.Errorf("no cpu lines in %s", rootPath(s.Root, "proc/stat"))
+	}
+	return reading, nil
+}
+
+// Reads the pressure stall information of a resource, nil if it is not available.
+func (s *UsageSampler) readPressure(resource string) *Pressure {
+	content, err := os.ReadFile(rootPath(s.Root, "proc/pressure/"+resource))
+	if err != nil {
+		return nil
+	}
+	pressure, err := ParsePressure(string(content))
+	if err != nil {
+		return nil
+	}
+	return pressure
+}
+
+// Parses the content of a pressure stall information file like /proc/pressure/memory.
+//
+// Parameters:
+//   - content: string - the content of the file
+//
+// Returns:
+//   - *Pressure: the parsed values, Full is empty for files without a full line
+//   - error: if a line is malformed
+func ParsePressure(content string) (*Pressure, error) {
+	pressure := &Pressure{}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var stats *PressureStats
+		switch fields[0] {
+		case "some":
+			stats = &pressure.Some
+		case "full":
+			stats = &pressure.Full
+		default:
+			return nil, fmt.Errorf("unexpected pressure line %q", line)
+		}
+
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			var err error
+			switch key {
+			case "avg10":
+				stats.Avg10, err = strconv.ParseFloat(value, 64)
+			case "avg60":
+				stats.Avg60, err = strconv.ParseFloat(value, 64)
+			case "avg300":
+				stats.Avg300, err = strconv.ParseFloat(value, 64)
+			case "total":
+				var usec int64
+				usec, err = strconv.ParseInt(value, 10, 64)
+				stats.Total = time.Duration(usec) * time.Microsecond
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unexpected pressure value %q: %w", field, err)
+			}
+		}
+	}
+	return pressure, nil
+}
+
+// Computes the percentages of time spent in each state since the earlier reading.
+func (t cpuTimes) usageSince(before cpuTimes) CPUUsage {
+	user := counterDelta(t.user, before.user) + counterDelta(t.nice, before.nice)
+	system := counterDelta(t.system, before.system) + counterDelta(t.irq, before.irq) +
+		counterDelta(t.softirq, before.softirq)
+	idle := counterDelta(t.idle, before.idle)
+	iowait := counterDelta(t.iowait, before.iowait)
+	steal := counterDelta(t.steal, before.steal)
+
+	usage := CPUUsage{Name: t.name}
+	total := float64(user + system + idle + iowait + steal)
+	if total == 0 {
+		return usage
+	}
+
+	usage.User = float64(user) / total * 100
+	usage.System = float64(system) / total * 100
+	usage.Idle = float64(idle) / total * 100
+	usage.IOWait = float64(iowait) / total * 100
+	usage.Steal = float64(steal) / total * 100
+	usage.Busy = 100 - usage.Idle - usage.IOWait
+	return usage
+}
+
+// Returns the increase of a counter, 0 if it was reset in between.
+func counterDelta(current, previous uint64) uint64 {
+	if current < previous {
+		return 0
+	}
+	return current - previous
+}
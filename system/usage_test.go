@@ -0,0 +1,193 @@
+package system
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.001
+}
+
+func TestUsageSampler(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"proc/uptime": "100.00 150.00\n",
+		"proc/stat": "cpu  1000 0 500 8000 500 0 0 0 0 0\n" +
+			"cpu0 500 0 250 4000 250 0 0 0 0 0\n" +
+			"cpu1 500 0 250 4000 250 0 0 0 0 0\n" +
+			"intr 5000 0 0\nctxt 20000\nprocesses 300\nprocs_running 2\nprocs_blocked 1\n",
+		"proc/pressure/memory": "some avg10=1.50 avg60=0.75 avg300=0.20 total=123456\n" +
+			"full avg10=0.50 avg60=0.25 avg300=0.00 total=6543\n",
+	})
+
+	sampler := &UsageSampler{Root: root}
+	first, err := sampler.Sample()
+	if err != nil {
+		t.Fatalf("Failed to take the first sample: %v", err)
+	}
+	// The first sample covers the time since boot
+	if first.Elapsed != 100*time.Second || !almostEqual(first.ContextSwitches, 200) || !almostEqual(first.CPU.Busy, 15) {
+		t.Errorf("Unexpected first sample: %+v", first)
+	}
+	if first.MemoryPressure == nil || first.MemoryPressure.Some.Avg10 != 1.5 || first.MemoryPressure.Full.Total != 6543*time.Microsecond {
+		t.Errorf("Unexpected memory pressure: %+v", first.MemoryPressure)
+	}
+	if first.CPUPressure != nil {
+		t.Errorf("Expected no CPU pressure without the file, got %+v", first.CPUPressure)
+	}
+
+	writeFixture(t, root, map[string]string{
+		"proc/uptime": "102.00 151.00\n",
+		"proc/stat": "cpu  1300 0 600 8100 500 0 0 0 0 0\n" +
+			"cpu0 550 0 300 4100 250 0 0 0 0 0\n" +
+			"cpu1 750 0 300 4000 250 0 0 0 0 0\n" +
+			"intr 5400 0 0\nctxt 21000\nprocesses 310\nprocs_running 4\nprocs_blocked 0\n",
+	})
+
+	second, err := sampler.Sample()
+	if err != nil {
+		t.Fatalf("Failed to take the second sample: %v", err)
+	}
+	if second.Elapsed != 2*time.Second || !almostEqual(second.ContextSwitches, 500) ||
+		!almostEqual(second.Interrupts, 200) || !almostEqual(second.Forks, 5) || second.ProcsRunning != 4 {
+		t.Errorf("Unexpected rates: %+v", second)
+	}
+	if !almostEqual(second.CPU.Busy, 80) || !almostEqual(second.CPU.User, 60) || !almostEqual(second.CPU.Idle, 20) {
+		t.Errorf("Unexpected total CPU usage: %+v", second.CPU)
+	}
+	if len(second.Cores) != 2 || !almostEqual(second.Cores[0].Busy, 50) || !almostEqual(second.Cores[1].Busy, 100) {
+		t.Errorf("Unexpected per-core usage: %+v", second.Cores)
+	}
+}
+
+func TestUsageSamplerSmoothing(t *testing.T) {
+	root := t.TempDir()
+	sampler := &UsageSampler{Root: root, Smoothing: 0.75}
+
+	samples := []struct {
+		uptime string
+		stat   string
+	}{
+		{"10.00 0\n", "cpu  0 0 0 1000 0 0 0 0\ncpu0 0 0 0 1000 0 0 0 0\nctxt 0\n"},
+		{"11.00 0\n", "cpu  0 0 0 1100 0 0 0 0\ncpu0 0 0 0 1100 0 0 0 0\nctxt 100\n"},
+		{"12.00 0\n", "cpu  100 0 0 1100 0 0 0 0\ncpu0 100 0 0 1100 0 0 0 0\nctxt 1100\n"},
+	}
+
+	var last UsageSnapshot
+	for _, sample := range samples {
+		writeFixture(t, root, map[string]string{"proc/uptime": sample.uptime, "proc/stat": sample.stat})
+		snapshot, err := sampler.Sample()
+		if err != nil {
+			t.Fatalf("Failed to take a sample: %v", err)
+		}
+		last = *snapshot
+		last.Cores = append([]CPUUsage(nil), snapshot.Cores...)
+
+		// Modifying a returned snapshot must not affect the smoothing of the next one
+		snapshot.CPU.Busy = 1000
+		snapshot.ContextSwitches = 1000
+		for i := range snapshot.Cores {
+			snapshot.Cores[i].Busy = 1000
+		}
+	}
+
+	// 0.75 * previous + 0.25 * current
+	if !almostEqual(last.CPU.Busy, 25) || !almostEqual(last.ContextSwitches, 268.75) {
+		t.Errorf("Unexpected smoothed values. Expected: 25%% and 268.75/s, Got: %.2f%% and %.2f/s",
+			last.CPU.Busy, last.ContextSwitches)
+	}
+	if len(last.Cores) != 1 || !almostEqual(last.Cores[0].Busy, 25) {
+		t.Errorf("Unexpected smoothed per-core usage: %+v", last.Cores)
+	}
+}
+
+func TestParsePressure(t *testing.T) {
+	pressure, err := ParsePressure("some avg10=12.34 avg60=5.00 avg300=1.00 total=1000000\n")
+	if err != nil {
+		t.Fatalf("Failed to parse pressure: %v", err)
+	}
+	if pressure.Some.Avg10 != 12.34 || pressure.Some.Total != time.Second || pressure.Full != (PressureStats{}) {
+		t.Errorf("Unexpected pressure: %+v", pressure)
+	}
+
+	if _, err := ParsePressure("some avg10=abc\n"); err == nil {
+		t.Error("Expected an error for a malformed value")
+	}
+}
+
+func TestUsageSamplerRun(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"proc/uptime": "10.00 0\n",
+		"proc/stat":   "cpu  0 0 0 1000 0 0 0 0\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sampler := &UsageSampler{Root: root, Interval: 10 * time.Millisecond}
+	snapshots := make(chan UsageSnapshot)
+	done := make(chan error, 1)
+	go func() {
+		done <- sampler.Run(ctx, snapshots)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-snapshots:
+		case <-time.After(5 * time.Second):
+			t.Fatal("No snapshot received")
+		}
+	}
+	cancel()
+
+	for range snapshots {
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestUsageSamplerRunIgnoresBaseline(t *testing.T) {
+	root := t.TempDir()
+	// Busy since boot, idle during the interval
+	writeFixture(t, root, map[string]string{
+		"proc/uptime": "10.00 0\n",
+		"proc/stat":   "cpu  1000 0 0 0 0 0 0 0\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sampler := &UsageSampler{Root: root, Interval: 200 * time.Millisecond, Smoothing: 0.75}
+	snapshots := make(chan UsageSnapshot)
+	go sampler.Run(ctx, snapshots)
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		sampler.mu.Lock()
+		baseline := sampler.previous != nil
+		sampler.mu.Unlock()
+		if baseline {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("No baseline sample taken")
+		}
+	}
+	writeFixture(t, root, map[string]string{
+		"proc/uptime": "11.00 0\n",
+		"proc/stat":   "cpu  1000 0 0 100 0 0 0 0\n",
+	})
+
+	select {
+	case snapshot := <-snapshots:
+		if !almostEqual(snapshot.CPU.Busy, 0) || !almostEqual(snapshot.CPU.Idle, 100) {
+			t.Errorf("Expected the first snapshot to cover only the interval, got: %+v", snapshot.CPU)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No snapshot received")
+	}
+}
@@ -0,0 +1,232 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Returned (wrapped) by CheckCommandVersion if the installed version is older than required.
+var ErrVersionTooOld = errors.New("version too old")
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?(?:-([0-9A-Za-z][0-9A-Za-z.-]*))?`)
+
+// Holds a semantic version as reported by a tool.
+//
+// Fields:
+//   - Major: int - the major version
+//   - Minor: int - the minor version
+//   - Patch: int - the patch version, 0 if the tool did not report one
+//   - PreRelease: string - the pre-release suffix after the dash, e.g. "rc1"
+type Version struct {
+	Major      int    `json:"major" bson:"major" yaml:"major"`
+	Minor      int    `json:"minor" bson:"minor" yaml:"minor"`
+	Patch      int    `json:"patch" bson:"patch" yaml:"patch"`
+	PreRelease string `json:"pre_release" bson:"pre_release" yaml:"pre_release"`
+}
+
+// Holds the result of a toolchain check.
+//
+// Fields:
+//   - Name: string - the command that was checked
+//   - Path: string - the absolute path the command resolved to
+//   - Version: Version - the version reported by the command
+type CommandInfo struct {
+	Name    string  `json:"name" bson:"name" yaml:"name"`
+	Path    string  `json:"path" bson:"path" yaml:"path"`
+	Version Version `json:"version" bson:"version" yaml:"version"`
+}
+
+// Extracts the first version number from arbitrary text, such as the output of
+// 'git --version' ("git version 2.39.2") or 'go version' ("go version go1.21.3 linux/amd64").
+// At least a major and a minor version have to be present. A leading 'v' or '>=' is ignored.
+//
+// Parameters:
+//   - text: string - the text containing the version
+//
+// Returns:
+//   - Version: the parsed version
+//   - error: if the text does not contain a version number
+//
+// Example usage:
+//
+//	version, err := ParseVersion("Docker version 24.0.5, build ced0996")
+func ParseVersion(text string) (Version, error) {
+	match := versionPattern.FindStringSubmatch(text)
+	if match == nil {
+		return Version{}, fmt.Errorf("no version number found in %q", text)
+	}
+
+	var version Version
+	version.Major, _ = strconv.Atoi(match[1])
+	version.Minor, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		version.Patch, _ = strconv.Atoi(match[3])
+	}
+	version.PreRelease = match[4]
+	return version, nil
+}
+
+// Returns the version in the form MAJOR.MINOR.PATCH[-PRERELEASE].
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	return s
+}
+
+// Compares two versions following semantic versioning precedence.
+// Returns -1 if v is older than other, 0 if they are equal and 1 if v is newer.
+// A pre-release is older than the release it precedes.
+func (v Version) Compare(other Version) int {
+	for _, pair := range [][2]int{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if pair[0] < pair[1] {
+			return -1
+		}
+		if pair[0] > pair[1] {
+			return 1
+		}
+	}
+
+	switch {
+	case v.PreRelease == other.PreRelease:
+		return 0
+	case v.PreRelease == "":
+		return 1
+	case other.PreRelease == "":
+		return -1
+	}
+	return comparePreRelease(v.PreRelease, other.PreRelease)
+}
+
+// Returns true if v is the same as or newer than the minimum version.
+func (v Version) AtLeast(minimum Version) bool {
+	return v.Compare(minimum) >= 0
+}
+
+// Compares dot separated pre-release identifiers, numeric identifiers numerically.
+func comparePreRelease(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	switch {
+	case len(aParts) < len(bParts):
+		return -1
+	case len(aParts) > len(bParts):
+		return 1
+	}
+	return 0
+}
+
+// Runs a command to query its version and parses the first version number from its output.
+// Without arguments the command is called with '--version'. Both stdout and stderr are
+// searched, since some tools print their version to stderr.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of the version command
+//   - command: string - the command to query
+//   - args: ...string - the arguments printing the version, '--version' if omitted
+//
+// Returns:
+//   - Version: the reported version
+//   - error: if the command failed or printed no version number
+//
+// Example usage:
+//
+//	version, err := CommandVersion(ctx, "go", "version")
+func CommandVersion(ctx context.Context, command string, args ...string) (Version, error) {
+	version, err := commandVersion(ctx, command, args...)
+	if err != nil {
+		return Version{}, fmt.Errorf("%s: %w", command, err)
+	}
+	return version, nil
+}
+
+// Runs the version command of the executable, the errors do not name the command.
+func commandVersion(ctx context.Context, path string, args ...string) (Version, error) {
+	if len(args) == 0 {
+		args = []string{"--version"}
+	}
+
+	result, err := NewCommand(path, args...).Run(ctx)
+	if err != nil {
+		return Version{}, err
+	}
+
+	version, err := ParseVersion(result.StdoutString() + "\n" + result.StderrString())
+	if err != nil {
+		return Version{}, fmt.Errorf("failed to detect version: %w", err)
+	}
+	return version, nil
+}
+
+// Validates that a command is installed and at least the given version, which is
+// typically done once at startup for every external tool a service depends on.
+//
+// Parameters:
+//   - ctx: context.Context - controls the lifetime of the version command
+//   - command: string - the command to check
+//   - minimum: string - the minimum version, e.g. "2.30" or ">=2.30.1"
+//   - args: ...string - the arguments printing the version, '--version' if omitted
+//
+// Returns:
+//   - *CommandInfo: the resolved path and version, also returned if the version is too old
+//   - error: an error wrapping exec.ErrNotFound if the command is missing, or
+//     ErrVersionTooOld if it is older than required
+//
+// Example usage:
+//
+//	info, err := CheckCommandVersion(ctx, "git", "2.30")
+//	if err != nil {
+//	  log.Fatalf("unsupported toolchain: %v", err)
+//	}
+func CheckCommandVersion(ctx context.Context, command string, minimum string, args ...string) (*CommandInfo, error) {
+	required, err := ParseVersion(minimum)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minimum version: %w", err)
+	}
+
+	path, err := LookupCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	// The resolved path is executed, but errors name the command as the caller passed it
+	version, err := commandVersion(ctx, path, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", command, err)
+	}
+
+	info := &CommandInfo{Name: command, Path: path, Version: version}
+	if !version.AtLeast(required) {
+		return info, fmt.Errorf("%s: %w: %s is installed, %s is required", command, ErrVersionTooOld, version, required)
+	}
+	return info, nil
+}
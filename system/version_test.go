@@ -0,0 +1,113 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{text: "git version 2.39.2", expected: "2.39.2"},
+		{text: "go version go1.21.3 linux/amd64", expected: "1.21.3"},
+		{text: "GNU bash, version 5.2.15(1)-release (x86_64-pc-linux-gnu)", expected: "5.2.15"},
+		{text: "Docker version 24.0.5, build ced0996", expected: "24.0.5"},
+		{text: "openjdk version \"17.0\"", expected: "17.0.0"},
+		{text: "v1.4.0-rc.1", expected: "1.4.0-rc.1"},
+		{text: ">=2.30", expected: "2.30.0"},
+	}
+
+	for _, test := range tests {
+		version, err := ParseVersion(test.text)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", test.text, err)
+			continue
+		}
+		if version.String() != test.expected {
+			t.Errorf("For %q, expected version %s, but got %s", test.text, test.expected, version)
+		}
+	}
+
+	if _, err := ParseVersion("no version here 42"); err == nil {
+		t.Error("Expected an error for text without a version number")
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0", "1.2.0", "1.10.0", "2.0.0"}
+
+	for i := 0; i < len(ordered)-1; i++ {
+		older, _ := ParseVersion(ordered[i])
+		newer, _ := ParseVersion(ordered[i+1])
+		if older.Compare(newer) != -1 || newer.Compare(older) != 1 {
+			t.Errorf("Expected %s < %s", older, newer)
+		}
+		if !newer.AtLeast(older) || older.AtLeast(newer) {
+			t.Errorf("Unexpected AtLeast result for %s and %s", older, newer)
+		}
+	}
+}
+
+func TestLookupCommand(t *testing.T) {
+	path, err := LookupCommand("sh")
+	if err != nil {
+		t.Fatalf("Failed to look up sh: %v", err)
+	}
+	if !filepath.IsAbs(path) {
+		t.Errorf("Expected an absolute path, got %s", path)
+	}
+
+	_, err = LookupCommand("system-test-missing-binary")
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Expected exec.ErrNotFound, got: %v", err)
+	}
+}
+
+func TestCheckCommandVersion(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.AddPath("git", "/usr/bin/git")
+	fake.On(`^/usr/bin/git --version$`).Stdout("git version 2.25.1\n")
+	restore := SetDefaultExecutor(fake)
+	defer restore()
+
+	ctx := context.Background()
+
+	info, err := CheckCommandVersion(ctx, "git", "2.20")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.Path != "/usr/bin/git" || info.Version.String() != "2.25.1" {
+		t.Errorf("Unexpected command info: %+v", info)
+	}
+
+	info, err = CheckCommandVersion(ctx, "git", ">=2.30")
+	if !errors.Is(err, ErrVersionTooOld) {
+		t.Errorf("Expected ErrVersionTooOld, got: %v", err)
+	}
+	if info == nil || info.Version.Minor != 25 {
+		t.Errorf("Expected the detected version alongside the error, got %+v", info)
+	}
+	if err != nil && !strings.HasPrefix(err.Error(), "git: ") {
+		t.Errorf("Expected the error to name the command as passed, got: %v", err)
+	}
+
+	broken := NewFakeExecutor()
+	broken.AddPath("git", "/usr/bin/git")
+	broken.On(`^/usr/bin/git --version$`).Stdout("no version here\n")
+	SetDefaultExecutor(broken)
+	_, err = CheckCommandVersion(ctx, "git", "2.20")
+	if err == nil || strings.Contains(err.Error(), "/usr/bin/git") {
+		t.Errorf("Expected an error naming git instead of its path, got: %v", err)
+	}
+
+	_, err = CheckCommandVersion(ctx, "docker", "20.0")
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Expected exec.ErrNotFound for a missing command, got: %v", err)
+	}
+}